@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"go.creack.net/fdf/math3"
+)
+
+// CalcOp is an element-wise operation between two operands.
+type CalcOp byte
+
+// CalcOp enum values.
+const (
+	CalcAdd  CalcOp = iota // a + b.
+	CalcSub                // a - b.
+	CalcMul                // a * b.
+	CalcDiv                // a / b, division by 0 gives a hole.
+	CalcMin                // Lowest of a and b.
+	CalcMax                // Highest of a and b.
+	CalcMask               // a where b is set and not 0, holes elsewhere.
+)
+
+// ParseCalcOp parses the operation: +, -, * (or x), /, min, max or mask.
+func ParseCalcOp(name string) (CalcOp, error) {
+	switch name {
+	case "+":
+		return CalcAdd, nil
+	case "-":
+		return CalcSub, nil
+	case "*", "x":
+		return CalcMul, nil
+	case "/":
+		return CalcDiv, nil
+	case "min":
+		return CalcMin, nil
+	case "max":
+		return CalcMax, nil
+	case "mask":
+		return CalcMask, nil
+	default:
+		return 0, fmt.Errorf("unknown operation %q, expected +, -, *, /, min, max or mask", name)
+	}
+}
+
+// apply computes the operation, NaN for a hole.
+// The operation is expected to be valid, see Calc.
+func (op CalcOp) apply(a, b float64) float64 {
+	switch op {
+	case CalcAdd:
+		return a + b
+	case CalcSub:
+		return a - b
+	case CalcMul:
+		return a * b
+	case CalcDiv:
+		if b == 0 {
+			return math.NaN()
+		}
+		return a / b
+	case CalcMin:
+		return math.Min(a, b)
+	case CalcMax:
+		return math.Max(a, b)
+	default: // CalcMask.
+		if b == 0 {
+			return math.NaN()
+		}
+		return a
+	}
+}
+
+// ColorSource defines where the colors of a computed map come from.
+type ColorSource byte
+
+// ColorSource enum values.
+const (
+	ColorsRecompute ColorSource = iota // Default color, so they follow the palette, if any.
+	ColorsFromA                        // Colors of the first operand.
+	ColorsFromB                        // Colors of the second operand.
+)
+
+// ParseColorSource parses the color source: none, a or b.
+func ParseColorSource(name string) (ColorSource, error) {
+	switch name {
+	case "none", "":
+		return ColorsRecompute, nil
+	case "a":
+		return ColorsFromA, nil
+	case "b":
+		return ColorsFromB, nil
+	default:
+		return 0, fmt.Errorf("unknown color source %q, expected none, a or b", name)
+	}
+}
+
+// Operand is either a map or a scalar.
+type Operand struct {
+	Map    *Map // Nil for a scalar.
+	Scalar float64
+}
+
+// at returns the operand's point at x/y, with the scalar as height if not a map.
+func (o Operand) at(x, y int) MapPoint {
+	if o.Map == nil {
+		return MapPoint{Vec: math3.Vec{Z: o.Scalar}, color: defaultColor}
+	}
+	return o.Map.Points[y][x]
+}
+
+// Calc computes "a op b", point by point. At least one of the operands must be a map,
+// the maps must have the same size. Holes in either operand give holes.
+//
+// The result takes the spacing and metadata of the first map operand.
+func Calc(op CalcOp, left, right Operand, colors ColorSource) (*Map, error) {
+	if op > CalcMask {
+		return nil, fmt.Errorf("unknown operation %d", op)
+	}
+	if colors > ColorsFromB {
+		return nil, fmt.Errorf("unknown color source %d", colors)
+	}
+	ref := left.Map
+	if ref == nil {
+		ref = right.Map
+	}
+	if ref == nil {
+		return nil, fmt.Errorf("at least one operand must be a map")
+	}
+	if left.Map != nil && right.Map != nil {
+		if err := sameSize(left.Map, right.Map); err != nil {
+			return nil, err
+		}
+	}
+	if (colors == ColorsFromA && left.Map == nil) || (colors == ColorsFromB && right.Map == nil) {
+		return nil, fmt.Errorf("can't take the colors from a scalar")
+	}
+
+	out := &Map{Points: make([][]MapPoint, len(ref.Points)), CellX: ref.CellX, CellY: ref.CellY, Meta: ref.Meta}
+	for y, line := range ref.Points {
+		out.Points[y] = make([]MapPoint, len(line))
+		for x, elem := range line {
+			pa, pb := left.at(x, y), right.at(x, y)
+			p := elem
+			if pa.IsHole() || pb.IsHole() {
+				p.Z = math.NaN()
+			} else {
+				p.Z = op.apply(pa.Z, pb.Z)
+			}
+			switch colors {
+			case ColorsRecompute:
+				p.color, p.explicit = defaultColor, false
+			case ColorsFromA:
+				p.color, p.explicit = pa.color, pa.explicit
+			case ColorsFromB:
+				p.color, p.explicit = pb.color, pb.explicit
+			}
+			out.Points[y][x] = p
+		}
+	}
+	return out, nil
+}
+
+// sameSize checks that both maps have the same size, row by row.
+func sameSize(a, b *Map) error {
+	if len(a.Points) != len(b.Points) {
+		return fmt.Errorf("size mismatch: %d rows in the first map, %d in the second", len(a.Points), len(b.Points))
+	}
+	for y := range a.Points {
+		if la, lb := len(a.Points[y]), len(b.Points[y]); la != lb {
+			return fmt.Errorf("size mismatch: row %d has %d points in the first map, %d in the second", y+1, la, lb)
+		}
+	}
+	return nil
+}
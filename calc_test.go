@@ -0,0 +1,82 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestCalc(t *testing.T) {
+	t.Parallel()
+
+	parse := func(str string) *Map {
+		t.Helper()
+		m, err := readMap(strings.NewReader(str), ParseOptions{})
+		if err != nil {
+			t.Fatalf("readMap: %s.", err)
+		}
+		return m
+	}
+	a := Operand{Map: parse("1 2 3,0xFF0000\n4 5 6\n")}
+	b := Operand{Map: parse("1 1 1\n0 _ 2,0x00FF00\n")}
+	mask := Operand{Map: parse("1 0 1\n1 1 _\n")}
+
+	for _, tc := range []struct {
+		op          CalcOp
+		left, right Operand
+		expect      string
+	}{
+		{CalcAdd, a, b, "2 3 4\n4 _ 8\n"},
+		{CalcSub, a, b, "0 1 2\n4 _ 4\n"},
+		{CalcMul, Operand{Scalar: 2}, a, "2 4 6\n8 10 12\n"},
+		{CalcDiv, a, b, "1 2 3\n_ _ 3\n"},
+		{CalcMin, a, Operand{Scalar: 3}, "1 2 3\n3 3 3\n"},
+		{CalcMax, a, b, "1 2 3\n4 _ 6\n"},
+		{CalcMask, a, mask, "1 _ 3\n4 5 _\n"},
+	} {
+		m, err := Calc(tc.op, tc.left, tc.right, ColorsRecompute)
+		if err != nil {
+			t.Fatalf("Calc %d: %s.", tc.op, err)
+		}
+		buf := strings.Builder{}
+		if err := encodeMap(&buf, &Map{Points: m.Points}, EncodeOptions{}); err != nil {
+			t.Fatalf("encodeMap: %s.", err)
+		}
+		if got := buf.String(); got != tc.expect {
+			t.Errorf("Unexpected result for op %d.\nGot:      %q\nExpected: %q", tc.op, got, tc.expect)
+		}
+	}
+
+	// Colors.
+	red, green := color.RGBA{R: 0xFF, A: 0xFF}, color.RGBA{G: 0xFF, A: 0xFF}
+	for colors, expect := range map[ColorSource][2]color.RGBA{
+		ColorsRecompute: {defaultColor, defaultColor},
+		ColorsFromA:     {red, defaultColor},
+		ColorsFromB:     {defaultColor, green},
+	} {
+		m, err := Calc(CalcAdd, a, b, colors)
+		if err != nil {
+			t.Fatalf("Calc: %s.", err)
+		}
+		if got := [2]color.RGBA{m.Points[0][2].color, m.Points[1][2].color}; got != expect {
+			t.Errorf("Unexpected colors for source %d.\nGot:      %v\nExpected: %v", colors, got, expect)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		op          CalcOp
+		left, right Operand
+		colors      ColorSource
+	}{
+		"size mismatch":        {CalcAdd, a, Operand{Map: parse("1 2 3\n4 5\n")}, ColorsRecompute},
+		"row count mismatch":   {CalcAdd, a, Operand{Map: parse("1 2 3\n")}, ColorsRecompute},
+		"scalars only":         {CalcAdd, Operand{Scalar: 1}, Operand{Scalar: 2}, ColorsRecompute},
+		"scalar colors":        {CalcAdd, a, Operand{Scalar: 2}, ColorsFromB},
+		"unknown operation":    {CalcMask + 1, a, Operand{Scalar: 2}, ColorsRecompute},
+		"unknown color source": {CalcAdd, a, Operand{Scalar: 2}, ColorsFromB + 1},
+	} {
+		if _, err := Calc(tc.op, tc.left, tc.right, tc.colors); err == nil {
+			t.Errorf("Expected error for %s.", name)
+		}
+	}
+}
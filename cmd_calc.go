@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// runCalc implements the 'calc' command: computes "a op b" point by point
+// and writes the result as .fdf, on stdout by default.
+func runCalc(args []string) error {
+	flags := flag.NewFlagSet("calc", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fdf calc [flags] <a> <op> <b>\n\n"+
+			"a and b are maps or numbers, op is one of +, -, * (or x), /, min, max or mask.\n\n")
+		flags.PrintDefaults()
+	}
+	colorsName := flags.String("colors", "none", "Colors of the result: 'none' to recompute them, 'a' or 'b' to take the operand's.")
+	outPath := flags.String("o", "-", "Output path, '-' for stdout. .obj/.ply/.stl export the mesh.")
+	var encOpts EncodeOptions
+	flags.BoolVar(&encOpts.Align, "align", false, "Only for .fdf: align the columns.")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if flags.NArg() != 3 {
+		flags.Usage()
+		return fmt.Errorf("expected 3 arguments, got %d", flags.NArg())
+	}
+
+	op, err := ParseCalcOp(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	colors, err := ParseColorSource(*colorsName)
+	if err != nil {
+		return err
+	}
+	left, err := loadOperand(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("operand a: %w", err)
+	}
+	right, err := loadOperand(flags.Arg(2))
+	if err != nil {
+		return fmt.Errorf("operand b: %w", err)
+	}
+
+	m, err := Calc(op, left, right, colors)
+	if err != nil {
+		return err
+	}
+	return writeMap(*outPath, NewFdfFromMap(m, "calc", LoadOptions{}), encOpts)
+}
+
+// loadOperand loads the map at the given source, or parses it as number
+// if there is no such file.
+func loadOperand(source string) (Operand, error) {
+	if _, err := os.Stat(source); err != nil && source != "-" {
+		if f, err := strconv.ParseFloat(source, 64); err == nil {
+			return Operand{Scalar: f}, nil
+		}
+	}
+	g, err := loadSource(source, LoadOptions{Progress: logProgress})
+	if err != nil {
+		return Operand{}, fmt.Errorf("load source: %w", err)
+	}
+	return Operand{Map: g.Map()}, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runDiff implements the 'diff' command: renders the second map colored by
+// its height difference with the first one and prints a summary of the changes.
+func runDiff(args []string) error {
+	var opts DiffOptions
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fdf diff [flags] <before> <after>\n\n"+
+			"Renders <after> colored by the height difference: blue for lower, red for higher.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Float64Var(&opts.Threshold, "threshold", 0, "Absolute height difference up to which a point is unchanged.")
+	flags.BoolVar(&opts.Dim, "dim", false, "Dim the unchanged points.")
+	flags.IntVar(&opts.Top, "top", 10, "Number of largest changes to print.")
+	var outPath, renderer, filePath string
+	flags.StringVar(&outPath, "o", "", "Write the colored map to the given path, '-' for stdout, instead of rendering it.")
+	flags.StringVar(&renderer, "r", "ebitengine", "Renderer when not using -o: 'png' or 'ebitengine'.")
+	flags.StringVar(&filePath, "f", "./fdf.png", "Only for 'png' renderer: path where to create the image.")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected 2 maps, got %d", flags.NArg())
+	}
+
+	before, err := loadSource(flags.Arg(0), LoadOptions{Progress: logProgress})
+	if err != nil {
+		return fmt.Errorf("load before: %w", err)
+	}
+	after, err := loadSource(flags.Arg(1), LoadOptions{Progress: logProgress})
+	if err != nil {
+		return fmt.Errorf("load after: %w", err)
+	}
+
+	m, summary, err := DiffMaps(before.Map(), after.Map(), opts)
+	if err != nil {
+		return err
+	}
+	// Stderr to keep stdout for the map output.
+	if err := summary.write(os.Stderr); err != nil {
+		return fmt.Errorf("write summary: %w", err)
+	}
+
+	// The diff map keeps the after's header, but not its title.
+	m.Meta.Title = "diff " + before.CurrentMapName() + " " + after.CurrentMapName()
+	g := NewFdfFromMap(m, m.Meta.Title, LoadOptions{})
+	if outPath != "" {
+		return writeMap(outPath, g, EncodeOptions{})
+	}
+	return runRenderer(renderer, filePath, g, 0)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runGen implements the 'gen' command: generates a procedural map and writes
+// it as .fdf, or renders it.
+func runGen(args []string) error {
+	opts := DefaultGenOptions()
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	flags.StringVar(&opts.Algorithm, "algo", opts.Algorithm, "Algorithm: "+strings.Join(GenAlgorithms(), ", ")+".")
+	size := flags.String("size", fmt.Sprintf("%dx%d", opts.Width, opts.Height), "Map size, 'N' or 'WxH'.")
+	flags.Int64Var(&opts.Seed, "seed", opts.Seed, "Random seed. The same seed always gives the same map.")
+	flags.Float64Var(&opts.MinHeight, "min", opts.MinHeight, "Lowest height.")
+	flags.Float64Var(&opts.MaxHeight, "max", opts.MaxHeight, "Highest height.")
+	flags.IntVar(&opts.Octaves, "octaves", opts.Octaves, "Only for perlin: number of noise layers.")
+	flags.Float64Var(&opts.Roughness, "roughness", opts.Roughness, "Only for perlin and diamond-square: amplitude ratio between two octaves/subdivisions, between 0 and 1.")
+	flags.IntVar(&opts.Decimals, "decimals", opts.Decimals, "Number of decimals of the heights.")
+	palette := flags.String("palette", "", "Palette set in the map header, see 'fdf -palette'.")
+	var outPath, renderer, filePath string
+	var encOpts EncodeOptions
+	flags.StringVar(&outPath, "o", "", "Write the map to the given path, '-' for stdout, instead of rendering it.")
+	flags.BoolVar(&encOpts.Align, "align", false, "Only with -o: align the columns.")
+	flags.StringVar(&renderer, "r", "ebitengine", "Renderer when not using -o: 'png' or 'ebitengine'.")
+	flags.StringVar(&filePath, "f", "./fdf.png", "Only for 'png' renderer: path where to create the image.")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+
+	var err error
+	if opts.Width, opts.Height, err = parseSize(*size); err != nil {
+		return fmt.Errorf("invalid -size: %w", err)
+	}
+
+	if _, err := ParseRamp(*palette); err != nil {
+		return fmt.Errorf("invalid -palette: %w", err)
+	}
+
+	m, err := Generate(opts)
+	if err != nil {
+		return fmt.Errorf("generate: %w", err)
+	}
+	m.Meta.Palette = *palette
+	g := NewFdfFromMap(m, m.Meta.Title, LoadOptions{})
+
+	if outPath != "" {
+		return writeMap(outPath, g, encOpts)
+	}
+	return runRenderer(renderer, filePath, g, 0)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runInfo implements the 'info' command: prints the statistics of the given maps
+// and the issues found in them.
+func runInfo(args []string) error {
+	flags := flag.NewFlagSet("info", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fdf info [flags] <map>...\n\n"+
+			"Prints the size, height statistics, colors and issues of the maps.\n"+
+			"Fails if a map can't be loaded, or has warnings with -strict.\n\n")
+		flags.PrintDefaults()
+	}
+	jsonOutput := flags.Bool("json", false, "Print a JSON array, one object per map.")
+	bins := flags.Int("bins", 10, "Number of bins of the height histogram.")
+	strict := flags.Bool("strict", false, "Fail on warnings as well.")
+	format := flags.String("format", "", "Force the input format instead of detecting it.")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no map given")
+	}
+
+	infos := make([]MapInfo, 0, flags.NArg())
+	failed := 0
+	for _, source := range flags.Args() {
+		info := inspectSource(source, *format, *bins)
+		if len(info.Errors) > 0 || (*strict && len(info.Warnings) > 0) {
+			failed++
+		}
+		infos = append(infos, info)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(infos); err != nil {
+			return fmt.Errorf("encode json: %w", err)
+		}
+	} else {
+		for i, elem := range infos {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := elem.write(os.Stdout); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d maps failed the checks", failed, len(infos))
+	}
+	return nil
+}
+
+// inspectSource loads and inspects the given map. Load errors are
+// reported in the info, the parse errors one by one.
+func inspectSource(source, format string, bins int) MapInfo {
+	var warnings []string
+	opts := LoadOptions{Format: format, Parse: ParseOptions{Warn: func(err *ParseError) {
+		if len(warnings) < maxParseErrors {
+			warnings = append(warnings, err.Error())
+		}
+	}}}
+	g, err := loadSource(source, opts)
+	if err != nil {
+		info := MapInfo{Name: source, Colors: []ColorUsage{}}
+		var parseErrs ParseErrors
+		if !errors.As(err, &parseErrs) {
+			info.Errors = append(info.Errors, err.Error())
+		}
+		for _, elem := range parseErrs {
+			info.Errors = append(info.Errors, elem.Error())
+		}
+		return info
+	}
+	info := Inspect(g.Map(), source, bins)
+	info.Warnings = append(warnings, info.Warnings...)
+	return info
+}
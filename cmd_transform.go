@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// runTransform implements the 'transform' command: applies the transforms,
+// in the order of the flags, to the input map and writes the result, or renders it.
+func runTransform(args []string) error {
+	var transforms []Transform
+	flags := flag.NewFlagSet("transform", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fdf transform [flags] <input> [output]\n\nThe transforms are applied in the order of the flags.\n\n")
+		flags.PrintDefaults()
+	}
+	interp := flags.String("interp", "bilinear", "Resample method: 'nearest', 'bilinear' or 'bicubic'.")
+	flags.Func("crop", "Crop to 'x,y,width,height'.", func(value string) error {
+		v, err := parseInts(value, 4)
+		if err != nil {
+			return err
+		}
+		transforms = append(transforms, Crop(v[0], v[1], v[2], v[3]))
+		return nil
+	})
+	flags.Func("resample", "Scale the number of points by the given factor, e.g. 0.5 halves them. See -interp.", func(value string) error {
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("parse float: %w", err)
+		}
+		// The method is resolved when applying, so -interp can be set anywhere.
+		transforms = append(transforms, func(m *Map) (*Map, error) {
+			method, err := ParseResampleMethod(*interp)
+			if err != nil {
+				return nil, err
+			}
+			return Resample(factor, method)(m)
+		})
+		return nil
+	})
+	for name, t := range map[string]Transform{
+		"rot90":     Rot90(),
+		"flipx":     FlipX(),
+		"flipy":     FlipY(),
+		"transpose": Transpose(),
+	} {
+		t := t
+		flags.BoolFunc(name, "Apply "+name+".", func(string) error {
+			transforms = append(transforms, t)
+			return nil
+		})
+	}
+	var renderer, filePath string
+	var encOpts EncodeOptions
+	flags.BoolVar(&encOpts.Align, "align", false, "Only with an .fdf output: align the columns.")
+	flags.StringVar(&renderer, "r", "ebitengine", "Renderer without output: 'png' or 'ebitengine'.")
+	flags.StringVar(&filePath, "f", "./fdf.png", "Only for 'png' renderer: path where to create the image.")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return fmt.Errorf("expected input and optional output, got %d arguments", flags.NArg())
+	}
+
+	g, err := loadSource(flags.Arg(0), LoadOptions{Progress: logProgress})
+	if err != nil {
+		return fmt.Errorf("load source: %w", err)
+	}
+	if err := g.Transform(transforms...); err != nil {
+		return err
+	}
+
+	if outPath := flags.Arg(1); outPath != "" {
+		return writeMap(outPath, g, encOpts)
+	}
+	return runRenderer(renderer, filePath, g, 0)
+}
@@ -8,43 +8,249 @@ import (
 	"strings"
 )
 
-// rgbaFromHexString parses the given string into RGBA.
+// rgbaFromHexString parses 0xRRGGBB, opaque, or 0xRRGGBBAA into RGBA, see rgbaFromUint.
+//
+// The digit count tells them apart, not the value: 0x0000FF80 is a translucent blue.
+// Up to 6 digits are RGB, i.e. 0xFF is blue.
 func rgbaFromHexString(str string) (color.RGBA, error) {
 	colStr := strings.TrimPrefix(str, "0x")
 	c, err := strconv.ParseUint(colStr, 16, 32)
 	if err != nil {
 		return color.RGBA{}, fmt.Errorf("parse uint: %w", err)
 	}
-	return rgbaFromUint(uint32(c)), nil
+	if len(colStr) == 8 {
+		return rgbaFromUint(uint32(c)), nil
+	}
+	if c > 0xFFFFFF {
+		return color.RGBA{}, fmt.Errorf("out of range, expected 0xRRGGBB or 0xRRGGBBAA")
+	}
+	return rgbaFromUint(uint32(c)<<8 | 0xFF), nil
 }
 
-// rgbaFromUint handles the bitshift magic to parse RGBA.
-// The magic is needed to support bot 0xFFFFFF and 0xFFFFFFFF.
+// rgbaFromUint converts 0xRRGGBBAA. The channels are straight, the returned color is
+// alpha-premultiplied, as expected from color.RGBA.
 func rgbaFromUint(rgba uint32) color.RGBA {
-	var cc color.RGBA
+	cc := color.NRGBA{R: uint8(rgba >> 24), G: uint8(rgba >> 16), B: uint8(rgba >> 8), A: uint8(rgba)}
+	return color.RGBAModel.Convert(cc).(color.RGBA) //nolint:forcetypeassert // Guaranteed by the model.
+}
 
-	if rgba > 0xFFFFFF {
-		cc.A = 8
-	}
-	cc.R = uint8((rgba >> (16 + cc.A)) & 0xFF)
-	cc.G = uint8((rgba >> (8 + cc.A)) & 0xFF)
-	cc.B = uint8((rgba >> cc.A) & 0xFF)
-	if cc.A != 0 {
-		cc.A = uint8(rgba & 0xFF)
-	}
+// ColorSpace is the space the colors are interpolated in along the edges.
+type ColorSpace byte
+
+// ColorSpace enum values.
+const (
+	ColorSpaceSRGB   ColorSpace = iota // Gamma encoded sRGB, the channels as stored.
+	ColorSpaceLinear                   // Linear RGB, physically correct light mixing.
+	ColorSpaceHSL                      // Hue, saturation, lightness, along the shortest hue path.
+	ColorSpaceHSV                      // Hue, saturation, value, along the shortest hue path.
+	ColorSpaceOKLab                    // Perceptually uniform, see https://bottosson.github.io/posts/oklab/.
+)
 
-	return cc
+// ParseColorSpace parses the color space name: srgb, linear, hsl, hsv or oklab.
+func ParseColorSpace(name string) (ColorSpace, error) {
+	switch name {
+	case "srgb", "":
+		return ColorSpaceSRGB, nil
+	case "linear":
+		return ColorSpaceLinear, nil
+	case "hsl":
+		return ColorSpaceHSL, nil
+	case "hsv":
+		return ColorSpaceHSV, nil
+	case "oklab":
+		return ColorSpaceOKLab, nil
+	default:
+		return 0, fmt.Errorf("unknown color space %q, expected srgb, linear, hsl, hsv or oklab", name)
+	}
 }
 
-// getGradientColor returns the color in between col1 and col2 with position as strength.
+// getGradientColor returns the color in between col1 and col2 with position as strength,
+// interpolated in the given color space.
 // position is a %, between 0 and 1.
-func getGradientColor(col1, col2 color.Color, position float64) color.Color {
+func getGradientColor(col1, col2 color.Color, position float64, space ColorSpace) color.RGBA {
+	// color.Color.RGBA returns alpha-premultiplied 16 bits channels.
 	r1, g1, b1, a1 := col1.RGBA()
 	r2, g2, b2, a2 := col2.RGBA()
-	return color.RGBA{
-		R: uint8(math.Round(float64(uint8(r1))*(1-position) + float64(uint8(r2))*position)),
-		G: uint8(math.Round(float64(uint8(g1))*(1-position) + float64(uint8(g2))*position)),
-		B: uint8(math.Round(float64(uint8(b1))*(1-position) + float64(uint8(b2))*position)),
-		A: uint8(math.Round(float64(uint8(a1))*(1-position) + float64(uint8(a2))*position)),
+	if space == ColorSpaceSRGB {
+		return color.RGBA{
+			R: uint8(math.Round(lerp(float64(r1>>8), float64(r2>>8), position))),
+			G: uint8(math.Round(lerp(float64(g1>>8), float64(g2>>8), position))),
+			B: uint8(math.Round(lerp(float64(b1>>8), float64(b2>>8), position))),
+			A: uint8(math.Round(lerp(float64(a1>>8), float64(a2>>8), position))),
+		}
+	}
+
+	// The other spaces work on straight, non-premultiplied, colors.
+	// The color of a transparent point is meaningless, only its alpha is interpolated.
+	c1, c2 := straightRGB(r1, g1, b1, a1), straightRGB(r2, g2, b2, a2)
+	switch {
+	case a1 == 0:
+		c1 = c2
+	case a2 == 0:
+		c2 = c1
+	}
+	var c [3]float64
+	switch space {
+	case ColorSpaceLinear:
+		for i := range c {
+			c[i] = linearToSRGB(lerp(srgbToLinear(c1[i]), srgbToLinear(c2[i]), position))
+		}
+	case ColorSpaceHSL:
+		c = hslToRGB(lerpHue(rgbToHSL(c1), rgbToHSL(c2), position))
+	case ColorSpaceHSV:
+		c = hsvToRGB(lerpHue(rgbToHSV(c1), rgbToHSV(c2), position))
+	case ColorSpaceOKLab:
+		l1, l2 := rgbToOKLab(c1), rgbToOKLab(c2)
+		for i := range c {
+			c[i] = lerp(l1[i], l2[i], position)
+		}
+		c = okLabToRGB(c)
+	}
+
+	a := lerp(float64(a1), float64(a2), position) / 0xFFFF
+	channel := func(v float64) uint8 { return uint8(math.Round(math.Max(0, math.Min(1, v)) * a * 0xFF)) }
+	return color.RGBA{R: channel(c[0]), G: channel(c[1]), B: channel(c[2]), A: uint8(math.Round(a * 0xFF))}
+}
+
+// lerp linearly interpolates between a and b.
+func lerp(a, b, t float64) float64 { return a*(1-t) + b*t }
+
+// straightRGB converts the premultiplied 16 bits channels to straight ones, between 0 and 1.
+func straightRGB(r, g, b, a uint32) [3]float64 {
+	if a == 0 {
+		return [3]float64{}
+	}
+	return [3]float64{float64(r) / float64(a), float64(g) / float64(a), float64(b) / float64(a)}
+}
+
+// srgbToLinear decodes the sRGB gamma of the given channel, between 0 and 1.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB encodes the given linear channel, between 0 and 1, with the sRGB gamma.
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// lerpHue interpolates the hue/saturation/x colors, the hue in degrees going the shortest way.
+// The hue of a gray is meaningless, the other color's is used.
+func lerpHue(c1, c2 [3]float64, t float64) [3]float64 {
+	switch {
+	case c1[1] == 0:
+		c1[0] = c2[0]
+	case c2[1] == 0:
+		c2[0] = c1[0]
+	}
+	dh := math.Mod(c2[0]-c1[0]+540, 360) - 180
+	return [3]float64{
+		math.Mod(c1[0]+dh*t+360, 360),
+		lerp(c1[1], c2[1], t),
+		lerp(c1[2], c2[2], t),
+	}
+}
+
+// rgbHue returns the hue, in degrees, the highest and the lowest channels of the color.
+func rgbHue(c [3]float64) (h, hi, lo float64) {
+	r, g, b := c[0], c[1], c[2]
+	hi, lo = math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	d := hi - lo
+	switch {
+	case d == 0:
+		return 0, hi, lo
+	case hi == r:
+		h = math.Mod((g-b)/d+6, 6)
+	case hi == g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h * 60, hi, lo
+}
+
+// hueToRGB returns the color of the given hue, in degrees, with the given chroma and lowest channel.
+func hueToRGB(h, chroma, lo float64) [3]float64 {
+	hh := h / 60
+	x := chroma * (1 - math.Abs(math.Mod(hh, 2)-1))
+	var c [3]float64
+	switch int(hh) % 6 {
+	case 0:
+		c = [3]float64{chroma, x, 0}
+	case 1:
+		c = [3]float64{x, chroma, 0}
+	case 2:
+		c = [3]float64{0, chroma, x}
+	case 3:
+		c = [3]float64{0, x, chroma}
+	case 4:
+		c = [3]float64{x, 0, chroma}
+	default:
+		c = [3]float64{chroma, 0, x}
+	}
+	return [3]float64{c[0] + lo, c[1] + lo, c[2] + lo}
+}
+
+// rgbToHSL converts the color to hue (degrees), saturation and lightness.
+func rgbToHSL(c [3]float64) [3]float64 {
+	h, hi, lo := rgbHue(c)
+	l := (hi + lo) / 2
+	s := 0.
+	if d := hi - lo; d > 0 {
+		s = d / (1 - math.Abs(2*l-1))
+	}
+	return [3]float64{h, s, l}
+}
+
+// hslToRGB converts the hue (degrees), saturation and lightness to RGB.
+func hslToRGB(c [3]float64) [3]float64 {
+	chroma := (1 - math.Abs(2*c[2]-1)) * c[1]
+	return hueToRGB(c[0], chroma, c[2]-chroma/2)
+}
+
+// rgbToHSV converts the color to hue (degrees), saturation and value.
+func rgbToHSV(c [3]float64) [3]float64 {
+	h, hi, lo := rgbHue(c)
+	s := 0.
+	if hi > 0 {
+		s = (hi - lo) / hi
+	}
+	return [3]float64{h, s, hi}
+}
+
+// hsvToRGB converts the hue (degrees), saturation and value to RGB.
+func hsvToRGB(c [3]float64) [3]float64 {
+	chroma := c[2] * c[1]
+	return hueToRGB(c[0], chroma, c[2]-chroma)
+}
+
+// rgbToOKLab converts the sRGB color to OKLab.
+func rgbToOKLab(c [3]float64) [3]float64 {
+	r, g, b := srgbToLinear(c[0]), srgbToLinear(c[1]), srgbToLinear(c[2])
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+	return [3]float64{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// okLabToRGB converts the OKLab color to sRGB, possibly out of gamut.
+func okLabToRGB(c [3]float64) [3]float64 {
+	l := c[0] + 0.3963377774*c[1] + 0.2158037573*c[2]
+	m := c[0] - 0.1055613458*c[1] - 0.0638541728*c[2]
+	s := c[0] - 0.0894841775*c[1] - 1.2914855480*c[2]
+	l, m, s = l*l*l, m*m*m, s*s*s
+	return [3]float64{
+		linearToSRGB(+4.0767416621*l - 3.3077115913*m + 0.2309699292*s),
+		linearToSRGB(-1.2684380046*l + 2.6097574011*m - 0.3413193965*s),
+		linearToSRGB(-0.0041960863*l - 0.7034186147*m + 1.7076147010*s),
 	}
 }
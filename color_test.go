@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestGetGradientColor(t *testing.T) {
+	t.Parallel()
+
+	var (
+		red         = color.RGBA{R: 0xFF, A: 0xFF}
+		green       = color.RGBA{G: 0xFF, A: 0xFF}
+		blue        = color.RGBA{B: 0xFF, A: 0xFF}
+		black       = color.RGBA{A: 0xFF}
+		white       = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+		transparent = color.RGBA{}
+	)
+
+	for name, tc := range map[string]struct {
+		col1, col2 color.Color
+		position   float64
+		space      ColorSpace
+		expect     color.RGBA
+	}{
+		// The 16 bits channels are narrowed down, not truncated to their low byte.
+		"srgb 16 bits":   {color.Gray16{Y: 0x1234}, color.Gray16{Y: 0x1234}, 0.5, ColorSpaceSRGB, color.RGBA{0x12, 0x12, 0x12, 0xFF}},
+		"linear 16 bits": {color.Gray16{Y: 0x1234}, color.Gray16{Y: 0x1234}, 0.5, ColorSpaceLinear, color.RGBA{0x12, 0x12, 0x12, 0xFF}},
+		"srgb non-rgba":  {color.NRGBA{R: 0xFF, A: 0x80}, color.NRGBA{R: 0xFF, A: 0x80}, 0, ColorSpaceSRGB, color.RGBA{R: 0x80, A: 0x80}},
+
+		"srgb start": {red, blue, 0, ColorSpaceSRGB, red},
+		"srgb end":   {red, blue, 1, ColorSpaceSRGB, blue},
+		"oklab end":  {red, blue, 1, ColorSpaceOKLab, blue},
+
+		"srgb red-green":   {red, green, 0.5, ColorSpaceSRGB, color.RGBA{128, 128, 0, 255}},
+		"linear red-green": {red, green, 0.5, ColorSpaceLinear, color.RGBA{188, 188, 0, 255}},
+		"hsl red-green":    {red, green, 0.5, ColorSpaceHSL, color.RGBA{255, 255, 0, 255}},
+		"hsv red-green":    {red, green, 0.5, ColorSpaceHSV, color.RGBA{255, 255, 0, 255}},
+		"oklab red-green":  {red, green, 0.5, ColorSpaceOKLab, color.RGBA{208, 168, 0, 255}},
+
+		// Shortest hue path: blue (240) to red (360), through purple, not green.
+		"srgb blue-red":  {blue, red, 0.25, ColorSpaceSRGB, color.RGBA{64, 0, 191, 255}},
+		"hsl blue-red":   {blue, red, 0.25, ColorSpaceHSL, color.RGBA{128, 0, 255, 255}},
+		"hsv blue-red":   {blue, red, 0.25, ColorSpaceHSV, color.RGBA{128, 0, 255, 255}},
+		"oklab blue-red": {blue, red, 0.25, ColorSpaceOKLab, color.RGBA{81, 71, 210, 255}},
+
+		"srgb black-white":   {black, white, 0.5, ColorSpaceSRGB, color.RGBA{128, 128, 128, 255}},
+		"linear black-white": {black, white, 0.5, ColorSpaceLinear, color.RGBA{188, 188, 188, 255}},
+		"hsl black-white":    {black, white, 0.5, ColorSpaceHSL, color.RGBA{128, 128, 128, 255}},
+		"oklab black-white":  {black, white, 0.5, ColorSpaceOKLab, color.RGBA{99, 99, 99, 255}},
+
+		// Grays take the hue of the other color.
+		"hsl white-blue": {white, blue, 0.5, ColorSpaceHSL, color.RGBA{159, 159, 223, 255}},
+		"hsv white-blue": {white, blue, 0.5, ColorSpaceHSV, color.RGBA{128, 128, 255, 255}},
+
+		// Transparent colors only fade the other one, premultiplied.
+		"srgb fade":   {red, transparent, 0.5, ColorSpaceSRGB, color.RGBA{128, 0, 0, 128}},
+		"linear fade": {red, transparent, 0.5, ColorSpaceLinear, color.RGBA{127, 0, 0, 128}},
+		"hsl fade":    {transparent, red, 0.5, ColorSpaceHSL, color.RGBA{128, 0, 0, 128}},
+		"oklab fade":  {red, transparent, 0.5, ColorSpaceOKLab, color.RGBA{128, 0, 0, 128}},
+	} {
+		if got := getGradientColor(tc.col1, tc.col2, tc.position, tc.space); got != tc.expect {
+			t.Errorf("Unexpected color for %s.\nGot:      %v\nExpected: %v", name, got, tc.expect)
+		}
+	}
+}
+
+func TestParseColorSpace(t *testing.T) {
+	t.Parallel()
+
+	for name, expect := range map[string]ColorSpace{
+		"":       ColorSpaceSRGB,
+		"srgb":   ColorSpaceSRGB,
+		"linear": ColorSpaceLinear,
+		"hsl":    ColorSpaceHSL,
+		"hsv":    ColorSpaceHSV,
+		"oklab":  ColorSpaceOKLab,
+	} {
+		got, err := ParseColorSpace(name)
+		if err != nil {
+			t.Fatalf("ParseColorSpace %q: %s.", name, err)
+		}
+		if got != expect {
+			t.Errorf("Unexpected color space for %q.\nGot:      %d\nExpected: %d", name, got, expect)
+		}
+	}
+	if _, err := ParseColorSpace("lab"); err == nil {
+		t.Error("Expected error for unknown color space.")
+	}
+}
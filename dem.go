@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// decodeASC decodes an ESRI ASCII Grid.
+//
+// The header provides the size, the cell spacing and the optional NODATA value:
+//
+//	ncols        4
+//	nrows        6
+//	xllcorner    0.0
+//	yllcorner    0.0
+//	cellsize     50.0
+//	NODATA_value -9999
+//
+// followed by nrows x ncols heights, north to south. NODATA cells become holes.
+func decodeASC(r io.Reader) (*Map, error) {
+	sc := bufio.NewScanner(r)
+	sc.Split(bufio.ScanWords)
+
+	header := map[string]float64{}
+	var tok string
+	for sc.Scan() {
+		tok = sc.Text()
+		// The header ends with the first numeric token.
+		if _, err := strconv.ParseFloat(tok, 64); err == nil {
+			break
+		}
+		key := strings.ToLower(tok)
+		switch key {
+		case "ncols", "nrows", "xllcorner", "yllcorner", "xllcenter", "yllcenter", "cellsize", "dx", "dy", "nodata_value":
+		default:
+			return nil, fmt.Errorf("unknown header key %q", tok)
+		}
+		if !sc.Scan() {
+			return nil, fmt.Errorf("missing value for header key %q", tok)
+		}
+		v, err := strconv.ParseFloat(sc.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for header key %q: %w", sc.Text(), tok, err)
+		}
+		header[key] = v
+		tok = ""
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	// Checked as floats before the conversion, a hostile header could overflow the int.
+	ncols, nrows := header["ncols"], header["nrows"]
+	if !(ncols >= 1 && ncols <= maxImageSide && ncols == math.Trunc(ncols)) ||
+		!(nrows >= 1 && nrows <= maxImageSide && nrows == math.Trunc(nrows)) {
+		return nil, fmt.Errorf("invalid grid size %vx%v", ncols, nrows)
+	}
+	width, height := int(ncols), int(nrows)
+	cellX, cellY := header["cellsize"], header["cellsize"]
+	if v, ok := header["dx"]; ok {
+		cellX = v
+	}
+	if v, ok := header["dy"]; ok {
+		cellY = v
+	}
+	if cellX <= 0 || cellY <= 0 {
+		return nil, fmt.Errorf("invalid cell size %v/%v", cellX, cellY)
+	}
+	noData, hasNoData := header["nodata_value"]
+
+	// The rows are allocated as they are read, so a truncated file can't claim a huge grid.
+	m := &Map{CellX: cellX, CellY: cellY}
+	for i := 0; i < width*height; i++ {
+		if i%width == 0 {
+			m.Points = append(m.Points, makeGrid(width, 1)[0])
+			for x := range m.Points[i/width] {
+				m.Points[i/width][x].Y = float64(i / width)
+			}
+		}
+		// The first value has already been read with the header.
+		if i > 0 || tok == "" {
+			if !sc.Scan() {
+				if err := sc.Err(); err != nil {
+					return nil, fmt.Errorf("read values: %w", err)
+				}
+				return nil, fmt.Errorf("unexpected end of grid after %d values, expected %d", i, width*height)
+			}
+			tok = sc.Text()
+		}
+		h, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid height %q for %d/%d: %w", tok, i/width, i%width, err)
+		}
+		if hasNoData && h == noData {
+			h = math.NaN()
+		}
+		m.Points[i/width][i%width].Z = h
+	}
+
+	return m, nil
+}
+
+// decodeXYZ decodes a XYZ DEM: one "x y z" point per line, separated by spaces,
+// tabs, commas or semicolons. Non numeric lines, i.e. column headers, are skipped.
+//
+// The points are expected to be on a regular grid, the spacing being the smallest
+// distance between two points. Missing points become holes.
+func decodeXYZ(r io.Reader) (*Map, error) {
+	type xyz struct{ x, y, z float64 }
+
+	var points []xyz
+	sc := bufio.NewScanner(r)
+	for lineNum := 1; sc.Scan(); lineNum++ {
+		fields := strings.FieldsFunc(sc.Text(), func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ',' || r == ';'
+		})
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected 3 values, got %d", lineNum, len(fields))
+		}
+		var p [3]float64
+		var err error
+		for i, elem := range fields {
+			if p[i], err = strconv.ParseFloat(elem, 64); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if len(points) == 0 { // Column header.
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		points = append(points, xyz{x: p[0], y: p[1], z: p[2]})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if len(points) == 0 {
+		return nil, errors.New("no points")
+	}
+
+	xs, ys := make([]float64, len(points)), make([]float64, len(points))
+	for i, p := range points {
+		xs[i], ys[i] = p.x, p.y
+	}
+	minX, maxX, cellX := gridAxis(xs)
+	minY, maxY, cellY := gridAxis(ys)
+
+	// Count the cells as floats, far apart or infinite coordinates would overflow ints.
+	cellsX := math.Round((maxX-minX)/cellX) + 1
+	cellsY := math.Round((maxY-minY)/cellY) + 1
+	// Sanity check to avoid allocating huge sparse grids from scattered points.
+	// Negated to catch NaN as well.
+	if !(cellsX*cellsY <= float64(4*len(points)+64)) {
+		return nil, fmt.Errorf("points are not on a regular grid (%gx%g cells for %d points)", cellsX, cellsY, len(points))
+	}
+	width, height := int(cellsX), int(cellsY)
+
+	m := &Map{Points: makeGrid(width, height), CellX: cellX, CellY: cellY}
+	for _, line := range m.Points {
+		for x := range line {
+			line[x].Z = math.NaN()
+		}
+	}
+	for _, p := range points {
+		// Y grows northward, rows go north to south.
+		x := int(math.Round((p.x - minX) / cellX))
+		y := int(math.Round((maxY - p.y) / cellY))
+		m.Points[y][x].Z = p.z
+	}
+
+	return m, nil
+}
+
+// gridAxis returns the min, max and spacing of the given coordinates.
+// The spacing is the smallest distance between two distinct values, 1 if all are equal.
+// Sorts values in place.
+func gridAxis(values []float64) (minVal, maxVal, spacing float64) {
+	slices.Sort(values)
+	values = slices.Compact(values)
+	spacing = math.Inf(1)
+	for i := 1; i < len(values); i++ {
+		spacing = math.Min(spacing, values[i]-values[i-1])
+	}
+	if math.IsInf(spacing, 1) {
+		spacing = 1
+	}
+	return values[0], values[len(values)-1], spacing
+}
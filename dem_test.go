@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeASC(t *testing.T) {
+	t.Parallel()
+
+	const asc = `ncols 3
+nrows 2
+xllcorner 100
+yllcorner 200
+cellsize 25
+NODATA_value -9999
+1 2.5 -9999
+4 5 6
+`
+	got, err := decodeASC(strings.NewReader(asc))
+	if err != nil {
+		t.Fatalf("decodeASC: %s.", err)
+	}
+	if got.CellX != 25 || got.CellY != 25 {
+		t.Errorf("Unexpected cell size %v/%v.", got.CellX, got.CellY)
+	}
+	if len(got.Points) != 2 || len(got.Points[0]) != 3 {
+		t.Fatalf("Unexpected grid size %dx%d.", len(got.Points[0]), len(got.Points))
+	}
+	if got.Points[0][1].Z != 2.5 || got.Points[1][2].Z != 6 {
+		t.Errorf("Unexpected heights: %v, %v.", got.Points[0][1].Z, got.Points[1][2].Z)
+	}
+	if !got.Points[0][2].IsHole() {
+		t.Errorf("NODATA cell should be a hole, got %v.", got.Points[0][2].Z)
+	}
+
+	if _, err := decodeASC(strings.NewReader("ncols 3\nnrows 2\ncellsize 1\n1 2 3\n")); err == nil {
+		t.Error("Expected error for truncated grid.")
+	}
+
+	// Hostile headers must be rejected, not allocated.
+	for _, header := range []string{
+		"ncols 3000000000\nnrows 3000000000\n",
+		"ncols 1e300\nnrows 2\n",
+		"ncols 2.5\nnrows 2\n",
+		"ncols 3\nnrows 0\n",
+	} {
+		if _, err := decodeASC(strings.NewReader(header + "cellsize 1\n1 2 3\n")); err == nil || !strings.Contains(err.Error(), "invalid grid size") {
+			t.Errorf("Unexpected error for header %q.\nGot:      %v\nExpected: invalid grid size", header, err)
+		}
+	}
+	// A valid but large header with a truncated grid only allocates what's read.
+	if _, err := decodeASC(strings.NewReader("ncols 32768\nnrows 32768\ncellsize 1\n1 2 3\n")); err == nil {
+		t.Error("Expected error for truncated large grid.")
+	}
+}
+
+func TestDecodeXYZ(t *testing.T) {
+	t.Parallel()
+
+	// Same grid as the ASC test, the NODATA point being missing.
+	const xyz = `x;y;z
+100;225;1
+125;225;2.5
+100;200;4
+125;200;5
+150;200;6
+`
+	got, err := decodeXYZ(strings.NewReader(xyz))
+	if err != nil {
+		t.Fatalf("decodeXYZ: %s.", err)
+	}
+	if got.CellX != 25 || got.CellY != 25 {
+		t.Errorf("Unexpected cell size %v/%v.", got.CellX, got.CellY)
+	}
+	if len(got.Points) != 2 || len(got.Points[0]) != 3 {
+		t.Fatalf("Unexpected grid size %dx%d.", len(got.Points[0]), len(got.Points))
+	}
+	if got.Points[0][1].Z != 2.5 || got.Points[1][2].Z != 6 {
+		t.Errorf("Unexpected heights: %v, %v.", got.Points[0][1].Z, got.Points[1][2].Z)
+	}
+	if !got.Points[0][2].IsHole() {
+		t.Errorf("Missing point should be a hole, got %v.", got.Points[0][2].Z)
+	}
+}
+
+func TestDecodeXYZFarApart(t *testing.T) {
+	t.Parallel()
+
+	for name, xyz := range map[string]string{
+		// The cell count overflows an int.
+		"overflow": "0 0 1\n1 1 2\n4e18 4e18 3\n",
+		"huge":     "0 0 1\n1 1 2\n1e300 1e300 3\n",
+		"infinite": "0 0 1\n1 1 2\ninf 0 3\n",
+	} {
+		if _, err := decodeXYZ(strings.NewReader(xyz)); err == nil {
+			t.Errorf("Expected error for %s coordinates.", name)
+		}
+	}
+}
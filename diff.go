@@ -0,0 +1,142 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+)
+
+// DiffOptions controls the difference visualization.
+type DiffOptions struct {
+	// Threshold is the absolute height difference up to which a point is unchanged.
+	Threshold float64
+
+	// Dim draws the unchanged points in dark gray instead of the ramp's middle color.
+	Dim bool
+
+	// Top is the number of largest changes listed in the summary.
+	Top int
+}
+
+// dimColor is the color of the unchanged points when dimmed.
+//
+//nolint:gochecknoglobals // Expected "readonly" global.
+var dimColor = color.RGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xFF}
+
+// DiffPoint is the height change of a point.
+type DiffPoint struct {
+	X, Y     int
+	From, To float64
+}
+
+// Delta returns the signed height difference.
+func (p DiffPoint) Delta() float64 { return p.To - p.From }
+
+// DiffSummary sums up the changes between two maps.
+type DiffSummary struct {
+	Points  int // Compared points, i.e. not holes.
+	Changed int // Points changing more than the threshold.
+
+	MeanAbs float64    // Mean absolute difference.
+	Rise    *DiffPoint // Largest rise, nil if none.
+	Fall    *DiffPoint // Largest fall, nil if none.
+
+	Largest []DiffPoint // By decreasing absolute difference.
+}
+
+// DiffMaps returns the surface of b colored by its signed height difference with a:
+// blue for lower, red for higher, using the diverging ramp scaled on the largest change.
+//
+// The maps must have the same size. Holes in either map give holes.
+func DiffMaps(a, b *Map, opts DiffOptions) (*Map, DiffSummary, error) {
+	var summary DiffSummary
+	if err := sameSize(a, b); err != nil {
+		return nil, summary, err
+	}
+
+	var changes []DiffPoint
+	maxAbs, sumAbs := 0., 0.
+	for y, line := range b.Points {
+		for x, elem := range line {
+			from := a.Points[y][x]
+			if elem.IsHole() || from.IsHole() {
+				continue
+			}
+			d := DiffPoint{X: x, Y: y, From: from.Z, To: elem.Z}
+			summary.Points++
+			sumAbs += math.Abs(d.Delta())
+			maxAbs = math.Max(maxAbs, math.Abs(d.Delta()))
+			if d.Delta() > 0 && (summary.Rise == nil || d.Delta() > summary.Rise.Delta()) {
+				summary.Rise = &d
+			}
+			if d.Delta() < 0 && (summary.Fall == nil || d.Delta() < summary.Fall.Delta()) {
+				summary.Fall = &d
+			}
+			if math.Abs(d.Delta()) > opts.Threshold {
+				summary.Changed++
+				changes = append(changes, d)
+			}
+		}
+	}
+	if summary.Points > 0 {
+		summary.MeanAbs = sumAbs / float64(summary.Points)
+	}
+	slices.SortStableFunc(changes, func(p1, p2 DiffPoint) int {
+		return cmp.Compare(math.Abs(p2.Delta()), math.Abs(p1.Delta()))
+	})
+	summary.Largest = changes[:min(len(changes), max(opts.Top, 0))]
+
+	ramp := ramps["diverging"]
+	out := &Map{Points: make([][]MapPoint, len(b.Points)), CellX: b.CellX, CellY: b.CellY, Meta: b.Meta}
+	out.Meta.Palette = "" // Colors are explicit.
+	for y, line := range b.Points {
+		out.Points[y] = slices.Clone(line)
+		for x, elem := range out.Points[y] {
+			from := a.Points[y][x]
+			if from.IsHole() {
+				out.Points[y][x].Z = math.NaN()
+				continue
+			}
+			d := elem.Z - from.Z
+			out.Points[y][x].explicit = true
+			switch {
+			case math.Abs(d) <= opts.Threshold && opts.Dim:
+				out.Points[y][x].color = dimColor
+			case maxAbs == 0:
+				out.Points[y][x].color = ramp.At(0.5)
+			default:
+				out.Points[y][x].color = ramp.At(0.5 + d/(2*maxAbs))
+			}
+		}
+	}
+	return out, summary, nil
+}
+
+// write prints the summary in a human readable form.
+func (s DiffSummary) write(w io.Writer) error {
+	extreme := func(p *DiffPoint) string {
+		if p == nil {
+			return "none"
+		}
+		return fmt.Sprintf("%+.4g at %d/%d", p.Delta(), p.X, p.Y)
+	}
+	if _, err := fmt.Fprintf(w, "Points:   %d, %d changed.\nMean:     %.4g\nRise:     %s.\nFall:     %s.\n",
+		s.Points, s.Changed, s.MeanAbs, extreme(s.Rise), extreme(s.Fall)); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if len(s.Largest) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(w, "Largest changes (x/y: from -> to):"); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	for _, elem := range s.Largest {
+		if _, err := fmt.Fprintf(w, "  %d/%d: %g -> %g (%+g)\n", elem.X, elem.Y, elem.From, elem.To, elem.Delta()); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDiffMaps(t *testing.T) {
+	t.Parallel()
+
+	parse := func(str string) *Map {
+		t.Helper()
+		m, err := readMap(strings.NewReader(str), ParseOptions{})
+		if err != nil {
+			t.Fatalf("readMap: %s.", err)
+		}
+		return m
+	}
+	a := parse("0 0 0\n5 5 _\n")
+	b := parse("4 0 0.5\n3 _ 5\n")
+
+	m, summary, err := DiffMaps(a, b, DiffOptions{Threshold: 1, Dim: true, Top: 2})
+	if err != nil {
+		t.Fatalf("DiffMaps: %s.", err)
+	}
+
+	ramp := ramps["diverging"]
+	if got, expect := m.Points[0][0].color, ramp.At(1); got != expect {
+		t.Errorf("Unexpected rise color.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if got, expect := m.Points[1][0].color, ramp.At(0.25); got != expect {
+		t.Errorf("Unexpected fall color.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if got := m.Points[0][2].color; got != dimColor {
+		t.Errorf("Unexpected unchanged color.\nGot:      %v\nExpected: %v", got, dimColor)
+	}
+	if !m.Points[1][1].IsHole() || !m.Points[1][2].IsHole() {
+		t.Errorf("Expected holes where either map has one.")
+	}
+	if got := m.Points[0][2].Z; got != 0.5 {
+		t.Errorf("Unexpected height, expected the second map's.\nGot:      %v\nExpected: %v", got, 0.5)
+	}
+
+	if summary.Points != 4 || summary.Changed != 2 {
+		t.Errorf("Unexpected counts.\nGot:      %d/%d\nExpected: %d/%d", summary.Points, summary.Changed, 4, 2)
+	}
+	if expect := 6.5 / 4; math.Abs(summary.MeanAbs-expect) > 1e-9 {
+		t.Errorf("Unexpected mean.\nGot:      %v\nExpected: %v", summary.MeanAbs, expect)
+	}
+	if summary.Rise == nil || summary.Fall == nil {
+		t.Fatalf("Missing rise/fall: %v/%v.", summary.Rise, summary.Fall)
+	}
+	if got, expect := *summary.Rise, (DiffPoint{X: 0, Y: 0, From: 0, To: 4}); got != expect {
+		t.Errorf("Unexpected rise.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if got, expect := *summary.Fall, (DiffPoint{X: 0, Y: 1, From: 5, To: 3}); got != expect {
+		t.Errorf("Unexpected fall.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if len(summary.Largest) != 2 || summary.Largest[0] != *summary.Rise || summary.Largest[1] != *summary.Fall {
+		t.Errorf("Unexpected largest changes.\nGot:      %v\nExpected: %v", summary.Largest, []DiffPoint{*summary.Rise, *summary.Fall})
+	}
+
+	// Without dimming, unchanged points follow the ramp.
+	m, _, err = DiffMaps(a, b, DiffOptions{Threshold: 1})
+	if err != nil {
+		t.Fatalf("DiffMaps: %s.", err)
+	}
+	if got, expect := m.Points[0][1].color, ramp.At(0.5); got != expect {
+		t.Errorf("Unexpected unchanged color.\nGot:      %v\nExpected: %v", got, expect)
+	}
+
+	// Identical maps have neither rise nor fall.
+	_, summary, err = DiffMaps(a, a, DiffOptions{})
+	if err != nil {
+		t.Fatalf("DiffMaps: %s.", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := summary.write(buf); err != nil {
+		t.Fatalf("write: %s.", err)
+	}
+	if got := buf.String(); !strings.Contains(got, "Rise:     none.\nFall:     none.\n") {
+		t.Errorf("Unexpected summary without changes.\nGot:      %q\nExpected: no rise nor fall", got)
+	}
+
+	if _, _, err := DiffMaps(a, parse("1 2 3\n"), DiffOptions{}); err == nil {
+		t.Error("Expected error for size mismatch.")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+)
+
+// EncodeOptions controls how maps are written.
+type EncodeOptions struct {
+	// Align pads the elements so the columns are aligned.
+	Align bool
+}
+
+// encodeMap writes the given map as .fdf text, with its header.
+//
+// Colors are written as 0xRRGGBB, or 0xRRGGBBAA when not opaque, and
+// omitted for the points without an explicit color: an explicit 0xFFFFFF
+// is written back even though it matches the default. Holes are written as '_'.
+// Empty rows are rejected: the parser skips blank lines, shifting the rows.
+func encodeMap(w io.Writer, m *Map, opts EncodeOptions) error {
+	points := m.Points
+	for y, line := range points {
+		if len(line) == 0 {
+			return fmt.Errorf("row %d: empty row can't be encoded", y+1)
+		}
+	}
+	var widths []int
+	if opts.Align {
+		for _, line := range points {
+			for x, elem := range line {
+				tok := formatPoint(nil, elem)
+				if x >= len(widths) {
+					widths = append(widths, 0)
+				}
+				widths[x] = max(widths[x], len(tok))
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	writeMeta(bw, m)
+	var tok []byte
+	for _, line := range points {
+		for x, elem := range line {
+			tok = formatPoint(tok[:0], elem)
+			if x > 0 {
+				_ = bw.WriteByte(' ') // Errors are sticky, checked on flush.
+			}
+			if opts.Align {
+				for i := len(tok); i < widths[x]; i++ {
+					_ = bw.WriteByte(' ')
+				}
+			}
+			_, _ = bw.Write(tok)
+		}
+		_ = bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+// formatPoint appends the "height[,color]" representation of the point to buf.
+func formatPoint(buf []byte, p MapPoint) []byte {
+	if p.IsHole() {
+		return append(buf, noDataToken...)
+	}
+	buf = appendHeight(buf, p.Z)
+	if p.explicit {
+		buf = append(buf, ',')
+		buf = appendHexColor(buf, p.color)
+	}
+	return buf
+}
+
+// appendHeight appends the height to buf, without decimals for integers.
+func appendHeight(buf []byte, h float64) []byte {
+	if h == math.Trunc(h) && math.Abs(h) < 1e15 {
+		return strconv.AppendInt(buf, int64(h), 10)
+	}
+	return strconv.AppendFloat(buf, h, 'g', -1, 64)
+}
+
+// appendHexColor appends the color as 0xRRGGBB, or 0xRRGGBBAA if not opaque,
+// with straight, non-premultiplied, channels.
+func appendHexColor(buf []byte, c color.RGBA) []byte {
+	if c.A == 0xFF {
+		return fmt.Appendf(buf, "0x%02X%02X%02X", c.R, c.G, c.B)
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA) //nolint:forcetypeassert // Guaranteed by the model.
+	return fmt.Appendf(buf, "0x%02X%02X%02X%02X", n.R, n.G, n.B, n.A)
+}
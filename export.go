@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+)
+
+// writeOBJ writes the mesh as Wavefront OBJ.
+func writeOBJ(w io.Writer, mesh *Mesh) error {
+	bw := bufio.NewWriter(w)
+
+	// Errors are sticky, checked on flush.
+	_, _ = bw.WriteString("# Generated by fdf.\n")
+	var buf []byte
+	for _, v := range mesh.Vertices {
+		buf = append(buf[:0], "v "...)
+		buf = strconv.AppendFloat(buf, v.X, 'g', -1, 64)
+		buf = append(buf, ' ')
+		buf = strconv.AppendFloat(buf, v.Y, 'g', -1, 64)
+		buf = append(buf, ' ')
+		buf = strconv.AppendFloat(buf, v.Z, 'g', -1, 64)
+		buf = append(buf, '\n')
+		_, _ = bw.Write(buf)
+	}
+	for _, f := range mesh.Faces {
+		// OBJ indices are 1-based.
+		buf = fmt.Appendf(buf[:0], "f %d %d %d\n", f[0]+1, f[1]+1, f[2]+1)
+		_, _ = bw.Write(buf)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+// writePLY writes the mesh as binary little endian PLY, with the opaque vertex colors.
+func writePLY(w io.Writer, mesh *Mesh) error {
+	bw := bufio.NewWriter(w)
+
+	// Errors are sticky, checked on flush.
+	_, _ = fmt.Fprintf(bw, `ply
+format binary_little_endian 1.0
+comment Generated by fdf.
+element vertex %d
+property float x
+property float y
+property float z
+property uchar red
+property uchar green
+property uchar blue
+element face %d
+property list uchar int vertex_indices
+end_header
+`, len(mesh.Vertices), len(mesh.Faces))
+
+	var buf [4 * 3 * 4]byte
+	for i, v := range mesh.Vertices {
+		binary.LittleEndian.PutUint32(buf[0:], math.Float32bits(float32(v.X)))
+		binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(float32(v.Y)))
+		binary.LittleEndian.PutUint32(buf[8:], math.Float32bits(float32(v.Z)))
+		// Straight colors, the alpha being dropped.
+		c := color.NRGBAModel.Convert(mesh.Colors[i]).(color.NRGBA) //nolint:forcetypeassert // Guaranteed by the model.
+		buf[12], buf[13], buf[14] = c.R, c.G, c.B
+		_, _ = bw.Write(buf[:15])
+	}
+	for _, f := range mesh.Faces {
+		buf[0] = 3
+		for i, idx := range f {
+			binary.LittleEndian.PutUint32(buf[1+4*i:], uint32(idx))
+		}
+		_, _ = bw.Write(buf[:13])
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+// writeSTL writes the mesh as binary STL.
+func writeSTL(w io.Writer, mesh *Mesh) error {
+	bw := bufio.NewWriter(w)
+
+	// Errors are sticky, checked on flush.
+	var header [80]byte
+	copy(header[:], "Generated by fdf.")
+	_, _ = bw.Write(header[:])
+	_ = binary.Write(bw, binary.LittleEndian, uint32(len(mesh.Faces)))
+
+	// Normal, 3 vertices and the attribute byte count.
+	var buf [4*3*4 + 2]byte
+	putVec := func(offset int, x, y, z float64) {
+		binary.LittleEndian.PutUint32(buf[offset:], math.Float32bits(float32(x)))
+		binary.LittleEndian.PutUint32(buf[offset+4:], math.Float32bits(float32(y)))
+		binary.LittleEndian.PutUint32(buf[offset+8:], math.Float32bits(float32(z)))
+	}
+	for _, f := range mesh.Faces {
+		n := mesh.normal(f)
+		putVec(0, n.X, n.Y, n.Z)
+		for i, idx := range f {
+			v := mesh.Vertices[idx]
+			putVec(12*(i+1), v.X, v.Y, v.Z)
+		}
+		_, _ = bw.Write(buf[:])
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
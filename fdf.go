@@ -1,17 +1,19 @@
 package main
 
 import (
-	"embed"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	"io"
 	"io/fs"
 	"math"
-	"strings"
+	"os"
+	"path"
 
 	"go.creack.net/fdf/math3"
 	"go.creack.net/fdf/projection"
+	"go.creack.net/fdf/render"
 )
 
 // Fdf reprensents the main engine to draw wireframes.
@@ -21,53 +23,223 @@ type Fdf struct {
 	projection projection.Projection
 
 	heightFactor float64
+	// Height factor set with SetDefaultHeightFactor, overriding the maps' header. 0 when unset.
+	defaultHeightFactor float64
 
-	mapData embed.FS
-	mapName string
+	// Distance between two points along X and Y, in height units.
+	cellX, cellY float64
+
+	meta MapMeta // Viewing settings from the map header.
+
+	palette      string        // Palette of the current map, see ParseRamp.
+	paletteSet   bool          // Whether the palette is set with SetPalette, overriding the maps' header.
+	ramp         Ramp          // Colors of the points without explicit color. Nil to keep the default.
+	ignoreColors bool          // Whether the ramp applies to the points with explicit color as well.
+	colorSpace   ColorSpace    // Space the colors are interpolated in along the edges.
+	transparent  bool          // Whether Draw leaves the background transparent.
+	theme        *render.Theme // Nil for the default one.
+	antialias    bool          // Whether Draw smooths the edges, slower.
+
+	filters []activeFilter
+	origZ   [][]float64 // Heights before the filters. Nil until a filter is set.
+
+	loadOpts LoadOptions
+
+	mapFS   fs.FS  // Filesystem the maps are loaded from. Nil when loaded from a reader.
+	mapPath string // Path of the current map within mapFS.
+}
+
+// LoadOptions controls how the maps are loaded.
+type LoadOptions struct {
+	// Progress, when set, is called periodically while reading a map.
+	Progress ProgressFunc
+
+	// Format forces the map format by name instead of looking it up
+	// from the file extension or content.
+	Format string
+
+	// Parse controls the .fdf parser.
+	Parse ParseOptions
+
+	// Heightmap controls how images are converted to maps.
+	Heightmap HeightmapOptions
 }
 
-// NewFdf loads/parses the map and creates a fdf engine.
-func NewFdf(mapData embed.FS, mapName string) (*Fdf, error) {
+// NewFdf loads/parses the map from the given filesystem and creates a fdf engine.
+//
+// mapFS can be any fs.FS: the embedded maps, os.DirFS, etc.
+func NewFdf(mapFS fs.FS, mapPath string, opts LoadOptions) (*Fdf, error) {
 	g := &Fdf{
 		projection:   projection.NewDirect(),
 		heightFactor: 1,
 
-		mapData: mapData,
+		loadOpts: opts,
+
+		mapFS: mapFS,
 	}
-	if err := g.LoadMap(mapName); err != nil {
-		return nil, fmt.Errorf("loadMap %q: %w", mapName, err)
+	if err := g.LoadMap(mapPath); err != nil {
+		return nil, fmt.Errorf("loadMap %q: %w", mapPath, err)
 	}
 	return g, nil
 }
 
-// CurrentMapName returns the current map name.
-func (m *Fdf) CurrentMapName() string { return m.mapName }
+// NewFdfFromReader loads/parses the map from the given reader and creates a fdf engine.
+//
+// As there is no backing filesystem, ListMaps will be empty.
+func NewFdfFromReader(r io.Reader, mapName string, opts LoadOptions) (*Fdf, error) {
+	g := &Fdf{
+		projection:   projection.NewDirect(),
+		heightFactor: 1,
 
-// ListMaps returns the list of available maps.
+		loadOpts: opts,
+	}
+	if err := g.LoadMapReader(r, mapName); err != nil {
+		return nil, fmt.Errorf("loadMapReader %q: %w", mapName, err)
+	}
+	return g, nil
+}
+
+// NewFdfFromMap creates a fdf engine from an already decoded map, i.e. generated.
+//
+// As there is no backing filesystem, ListMaps will be empty.
+func NewFdfFromMap(newMap *Map, mapName string, opts LoadOptions) *Fdf {
+	g := &Fdf{
+		projection:   projection.NewDirect(),
+		heightFactor: 1,
+
+		loadOpts: opts,
+
+		mapPath: mapName,
+	}
+	g.setMap(newMap)
+	return g
+}
+
+// CurrentMapName returns the current map name: its title if set in the header, the file name otherwise.
+func (m *Fdf) CurrentMapName() string {
+	if m.meta.Title != "" {
+		return m.meta.Title
+	}
+	return path.Base(m.mapPath)
+}
+
+// CurrentMapPath returns the path of the current map within the engine's filesystem.
+func (m *Fdf) CurrentMapPath() string { return m.mapPath }
+
+// CurrentMapStat returns the file info of the current map, i.e. to watch it for changes.
+// Fails if the map isn't backed by a filesystem.
+func (m *Fdf) CurrentMapStat() (fs.FileInfo, error) {
+	if m.mapFS == nil {
+		return nil, fmt.Errorf("no filesystem for %q", m.mapPath)
+	}
+	st, err := fs.Stat(m.mapFS, m.mapPath)
+	if err != nil {
+		return nil, fmt.Errorf("fs stat: %w", err)
+	}
+	return st, nil
+}
+
+// ListMaps returns the list of available maps, i.e. the supported map files
+// in the same directory as the current map.
 func (m *Fdf) ListMaps() []fs.DirEntry {
-	out, err := m.mapData.ReadDir("maps")
-	if err != nil { // Should never happen, but check just in case.
+	if m.mapFS == nil {
+		return nil
+	}
+	entries, err := fs.ReadDir(m.mapFS, path.Dir(m.mapPath))
+	if err != nil { // Should never happen as we loaded the current map from there, but check just in case.
 		panic(fmt.Errorf("open maps dir: %w", err))
 	}
+	out := entries[:0]
+	for _, elem := range entries {
+		if elem.Type().IsRegular() && isMapFile(elem.Name()) {
+			out = append(out, elem)
+		}
+	}
 	return out
 }
 
-// LoadMap loads the given map name.
-func (m *Fdf) LoadMap(mapName string) error {
-	fmt.Println("Loading new map", mapName)
-	buf, err := mapData.ReadFile(mapName)
+// LoadMap loads the given map path from the engine's filesystem.
+func (m *Fdf) LoadMap(mapPath string) error {
+	if m.mapFS == nil {
+		return fmt.Errorf("no filesystem to load %q from", mapPath)
+	}
+	fmt.Fprintln(os.Stderr, "Loading new map", mapPath) // Stderr to keep stdout for the map output.
+	f, err := m.mapFS.Open(mapPath)
+	if err != nil {
+		return fmt.Errorf("fs open: %w", err)
+	}
+	defer func() { _ = f.Close() }() // Best effort, read only.
+
+	total := int64(-1)
+	if st, err := f.Stat(); err == nil && st.Mode().IsRegular() {
+		total = st.Size()
+	}
+
+	newMap, err := decodeMap(newProgressReader(f, total, m.loadOpts.Progress), mapPath, m.loadOpts)
 	if err != nil {
-		return fmt.Errorf("fs readfile: %w", err)
+		return fmt.Errorf("decodeMap: %w", err)
 	}
-	newMap, err := parseMap(buf)
+	m.setMap(newMap)
+	m.mapPath = mapPath
+	return nil
+}
+
+// LoadMapReader loads the map from the given reader.
+// mapName is only used for display. The engine is detached from its
+// filesystem, if any, so ListMaps will be empty afterward.
+func (m *Fdf) LoadMapReader(r io.Reader, mapName string) error {
+	fmt.Fprintln(os.Stderr, "Loading new map", mapName)
+	newMap, err := decodeMap(newProgressReader(r, -1, m.loadOpts.Progress), mapName, m.loadOpts)
 	if err != nil {
-		return fmt.Errorf("parseMap: %w", err)
+		return fmt.Errorf("decodeMap: %w", err)
 	}
-	m.Points = newMap
-	m.mapName = strings.TrimPrefix(mapName, "maps/")
+	m.setMap(newMap)
+	m.mapFS = nil
+	m.mapPath = mapName
 	return nil
 }
 
+// setMap sets the decoded map as the current one and applies its viewing settings.
+func (m *Fdf) setMap(newMap *Map) {
+	m.Points = newMap.Points
+	m.cellX, m.cellY = newMap.CellX, newMap.CellY
+	m.origZ = nil
+	m.applyFilters()
+
+	m.meta = newMap.Meta
+	if !m.paletteSet {
+		m.palette = m.meta.Palette
+		m.ramp, _ = ParseRamp(m.palette) // Validated by the parser.
+	}
+	switch {
+	case m.defaultHeightFactor != 0:
+		m.heightFactor = m.defaultHeightFactor
+	case m.meta.HeightFactor != 0:
+		m.heightFactor = m.meta.HeightFactor
+	default: // Don't keep the previous map's.
+		m.heightFactor = 1
+	}
+	if m.meta.View.Angle != nil {
+		m.projection.SetAngle(*m.meta.View.Angle)
+	}
+	if m.meta.View.Scale > 0 {
+		m.projection.SetScale(float64(m.meta.View.Scale))
+	}
+}
+
+// Map returns the current map, with the filtered heights and the current height factor in its metadata.
+func (m *Fdf) Map() *Map {
+	meta := m.meta
+	if meta.HeightFactor != 0 || m.heightFactor != 1 {
+		meta.HeightFactor = m.heightFactor
+	}
+	meta.Palette = m.palette
+	return &Map{Points: m.Points, CellX: m.cellX, CellY: m.cellY, Meta: meta}
+}
+
+// DefaultView returns the camera preset of the current map.
+func (m *Fdf) DefaultView() projection.View { return m.meta.View }
+
 // GetProjection accesses the value.
 func (m *Fdf) GetProjection() projection.Projection { return m.projection }
 
@@ -94,37 +266,88 @@ func (m *Fdf) SetProjection(p projection.Projection) image.Rectangle {
 	return bounds
 }
 
+// SetColorSpace sets the space the colors are interpolated in along the edges.
+func (m *Fdf) SetColorSpace(space ColorSpace) { m.colorSpace = space }
+
+// SetTransparentBackground sets whether Draw leaves the background transparent instead of the theme's.
+func (m *Fdf) SetTransparentBackground(transparent bool) { m.transparent = transparent }
+
+// SetTheme sets the style of the rendering.
+func (m *Fdf) SetTheme(theme render.Theme) { m.theme = &theme }
+
+// SetAntialias sets whether Draw smooths the edges instead of the faster aliased lines.
+func (m *Fdf) SetAntialias(antialias bool) { m.antialias = antialias }
+
+// Antialias returns whether Draw smooths the edges.
+func (m *Fdf) Antialias() bool { return m.antialias }
+
+// Theme returns the style of the rendering.
+func (m *Fdf) Theme() render.Theme {
+	if m.theme == nil {
+		return render.DefaultTheme()
+	}
+	return *m.theme
+}
+
 // GetHeightFactor accesses the value.
 func (m *Fdf) GetHeightFactor() float64 { return m.heightFactor }
 
 // SetHeightFactor sets the value.
 func (m *Fdf) SetHeightFactor(f float64) { m.heightFactor = f }
 
+// SetDefaultHeightFactor sets the height factor of the current and next maps, overriding their header's.
+// 0 to use the headers again.
+func (m *Fdf) SetDefaultHeightFactor(f float64) {
+	m.defaultHeightFactor = f
+	if f != 0 {
+		m.heightFactor = f
+	}
+}
+
 // Draw renders the image.
 func (m *Fdf) Draw() image.Image {
 	bounds := m.getProjectedBounds()
 	img := image.NewRGBA(bounds)
 
-	// Add black background.
-	// Remove this to allow for transparency.
-	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Over)
+	// Add the theme's background, unless transparent.
+	theme := m.Theme()
+	switch {
+	case m.transparent:
+	case theme.HasGradient():
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			c := theme.BackgroundAt(float64(y-bounds.Min.Y) / float64(max(bounds.Dy()-1, 1)))
+			draw.Draw(img, image.Rect(bounds.Min.X, y, bounds.Max.X, y+1), image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	default:
+		draw.Draw(img, img.Bounds(), image.NewUniform(theme.Background), image.Point{}, draw.Over)
+	}
+
+	drawEdge := drawLine
+	if m.antialias {
+		drawEdge = drawLineAA
+	}
 
+	lo, hi := m.colorRange()
 	for y, line := range m.Points {
 		for x, elem := range line {
-			v := m.projection.Project(elem.Vec.ScaleZ(m.heightFactor))
+			// Skip holes, along with the edges touching them.
+			if elem.IsHole() {
+				continue
+			}
+			v := m.projection.Project(m.worldVec(elem.Vec).ScaleZ(m.heightFactor))
 			pv := image.Point{X: int(v.X), Y: int(v.Y)}
 
-			if x+1 < len(line) {
+			if x+1 < len(line) && !line[x+1].IsHole() {
 				elem1 := m.Points[y][x+1]
-				v1 := m.projection.Project(elem1.Vec.ScaleZ(m.heightFactor))
+				v1 := m.projection.Project(m.worldVec(elem1.Vec).ScaleZ(m.heightFactor))
 				pv1 := image.Point{X: int(v1.X), Y: int(v1.Y)}
-				drawLine(img, pv, pv1, elem.color, elem1.color)
+				drawEdge(img, pv, pv1, m.pointColor(elem, lo, hi), m.pointColor(elem1, lo, hi), m.colorSpace, theme.LineWidth)
 			}
-			if y+1 < len(m.Points) && x < len(m.Points[y+1]) {
+			if y+1 < len(m.Points) && x < len(m.Points[y+1]) && !m.Points[y+1][x].IsHole() {
 				elem1 := m.Points[y+1][x]
-				v1 := m.projection.Project(elem1.Vec.ScaleZ(m.heightFactor))
+				v1 := m.projection.Project(m.worldVec(elem1.Vec).ScaleZ(m.heightFactor))
 				pv1 := image.Point{X: int(v1.X), Y: int(v1.Y)}
-				drawLine(img, pv, pv1, elem.color, elem1.color)
+				drawEdge(img, pv, pv1, m.pointColor(elem, lo, hi), m.pointColor(elem1, lo, hi), m.colorSpace, theme.LineWidth)
 			}
 		}
 	}
@@ -132,6 +355,27 @@ func (m *Fdf) Draw() image.Image {
 	return img
 }
 
+// colorRange returns the height range used by the ramp, if any.
+func (m *Fdf) colorRange() (lo, hi float64) {
+	if m.ramp == nil {
+		return 0, 0
+	}
+	return heightRange(m.Points)
+}
+
+// pointColor returns the color of the point: its own if explicit, unless ignored, from the ramp otherwise,
+// the theme's edge color without ramp.
+// lo/hi is the height range, see colorRange.
+func (m *Fdf) pointColor(p MapPoint, lo, hi float64) color.RGBA {
+	if p.explicit && (m.ramp == nil || !m.ignoreColors) {
+		return p.color
+	}
+	if m.ramp == nil {
+		return m.Theme().Edge
+	}
+	return m.ramp.At((p.Z - lo) / (hi - lo))
+}
+
 // getProjectedBounds projects and scales each points of the map
 // and returns the smallest boundaries fitting everything.
 //
@@ -142,7 +386,10 @@ func (m *Fdf) getProjectedBounds() image.Rectangle {
 
 	for _, line := range m.Points {
 		for _, elem := range line {
-			point := m.projection.Project(elem.Vec)
+			if elem.IsHole() {
+				continue
+			}
+			point := m.projection.Project(m.worldVec(elem.Vec))
 
 			if math.Floor(point.X) < float64(bounds.Min.X) {
 				bounds.Min.X = int(math.Floor(point.X))
@@ -159,3 +406,16 @@ func (m *Fdf) getProjectedBounds() image.Rectangle {
 
 	return bounds
 }
+
+// worldVec applies the cell spacing to the given map vector.
+//
+// The result is normalized so the smallest spacing is 1, keeping the
+// projection scale independent from the map's unit.
+func (m *Fdf) worldVec(v math3.Vec) math3.Vec {
+	unit := min(m.cellX, m.cellY)
+	return math3.Vec{
+		X: v.X * m.cellX / unit,
+		Y: v.Y * m.cellY / unit,
+		Z: v.Z / unit,
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.creack.net/fdf/math3"
+	"go.creack.net/fdf/projection"
+	"go.creack.net/fdf/render"
+)
+
+func TestNewFdfFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"maps/a.fdf":     {Data: []byte("0 1\n2 3\n")},
+		"maps/b.fdf":     {Data: []byte("0 1 2\n3 4 5\n")},
+		"maps/notes.txt": {Data: []byte("Not a map.\n")},
+		"maps/sub/c.fdf": {Data: []byte("0 1\n2 3\n")},
+		"other.fdf":      {Data: []byte("0 1\n2 3\n")},
+	}
+	g, err := NewFdf(fsys, "maps/a.fdf", LoadOptions{})
+	if err != nil {
+		t.Fatalf("NewFdf: %s.", err)
+	}
+	if name, path := g.CurrentMapName(), g.CurrentMapPath(); name != "a.fdf" || path != "maps/a.fdf" {
+		t.Errorf("Unexpected current map %q, %q.", name, path)
+	}
+
+	// Only the map files of the current map's directory are listed.
+	var names []string
+	for _, elem := range g.ListMaps() {
+		names = append(names, elem.Name())
+	}
+	if expect := []string{"a.fdf", "b.fdf"}; !slices.Equal(names, expect) {
+		t.Errorf("Unexpected maps.\nGot:      %q\nExpected: %q", names, expect)
+	}
+
+	if err := g.LoadMap("maps/b.fdf"); err != nil {
+		t.Fatalf("LoadMap: %s.", err)
+	}
+	if len(g.Points[0]) != 3 || g.CurrentMapPath() != "maps/b.fdf" {
+		t.Errorf("Unexpected loaded map %q, %d points wide.", g.CurrentMapPath(), len(g.Points[0]))
+	}
+
+	// A failed load keeps the current map.
+	if err := g.LoadMap("maps/missing.fdf"); err == nil {
+		t.Error("Expected error for missing map.")
+	}
+	if g.CurrentMapPath() != "maps/b.fdf" {
+		t.Errorf("Unexpected current map %q after a failed load.", g.CurrentMapPath())
+	}
+	if _, err := NewFdf(fsys, "missing.fdf", LoadOptions{}); err == nil {
+		t.Error("Expected error for missing map.")
+	}
+
+	// Without filesystem, nothing to list nor load.
+	g, err = NewFdfFromReader(strings.NewReader("0 1\n2 3\n"), "stdin", LoadOptions{})
+	if err != nil {
+		t.Fatalf("NewFdfFromReader: %s.", err)
+	}
+	if maps := g.ListMaps(); maps != nil {
+		t.Errorf("Unexpected maps without filesystem: %v.", maps)
+	}
+	if err := g.LoadMap("other.fdf"); err == nil {
+		t.Error("Expected error loading without filesystem.")
+	}
+}
+
+func TestReloadIfChanged(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{"map.fdf": {Data: []byte("# height-factor: 3\n# projection: iso 1 0 0.5 20\n0 1\n2 3\n"), ModTime: modTime}}
+	g, err := NewFdf(fsys, "map.fdf", LoadOptions{})
+	if err != nil {
+		t.Fatalf("NewFdf: %s.", err)
+	}
+	last, err := g.CurrentMapStat()
+	if err != nil {
+		t.Fatalf("CurrentMapStat: %s.", err)
+	}
+
+	// The view set by the user, to be kept over the header's.
+	p := projection.NewIsomorphic(5)
+	p.SetAngle(math3.Vec{X: 0.1})
+	g.SetProjection(p)
+	g.SetHeightFactor(2)
+
+	assertView := func(name string) {
+		t.Helper()
+		p := g.GetProjection()
+		if p.GetScale() != 5 || p.GetAngle() != (math3.Vec{X: 0.1}) || g.GetHeightFactor() != 2 {
+			t.Errorf("Unexpected view %s.\nGot:      scale %v, angle %v, height factor %v\nExpected: scale 5, angle {0.1 0 0}, height factor 2",
+				name, p.GetScale(), p.GetAngle(), g.GetHeightFactor())
+		}
+	}
+
+	// Unchanged.
+	if st, reloaded, err := render.ReloadIfChanged(g, last); err != nil || reloaded || st != last {
+		t.Fatalf("Unexpected reload of an unchanged map: %v, %v.", reloaded, err)
+	}
+
+	// Changed.
+	modTime = modTime.Add(time.Second)
+	fsys["map.fdf"] = &fstest.MapFile{Data: []byte("# height-factor: 3\n# projection: iso 1 0 0.5 20\n0 1 2\n3 4 5\n"), ModTime: modTime}
+	last, reloaded, err := render.ReloadIfChanged(g, last)
+	if err != nil || !reloaded {
+		t.Fatalf("Expected reload of the changed map: %v, %v.", reloaded, err)
+	}
+	if len(g.Points[0]) != 3 {
+		t.Errorf("Unexpected reloaded map width %d.", len(g.Points[0]))
+	}
+	if !last.ModTime().Equal(modTime) {
+		t.Errorf("Unexpected file info.\nGot:      %v\nExpected: %v", last.ModTime(), modTime)
+	}
+	assertView("after reload")
+
+	// Invalid, the previous map is kept and not retried until changed again.
+	modTime = modTime.Add(time.Second)
+	fsys["map.fdf"] = &fstest.MapFile{Data: []byte("0 x\n"), ModTime: modTime}
+	last, reloaded, err = render.ReloadIfChanged(g, last)
+	if err == nil || reloaded {
+		t.Fatalf("Expected error for the invalid map: %v, %v.", reloaded, err)
+	}
+	if len(g.Points[0]) != 3 {
+		t.Errorf("Unexpected map width %d after a failed reload.", len(g.Points[0]))
+	}
+	if _, reloaded, err := render.ReloadIfChanged(g, last); err != nil || reloaded {
+		t.Errorf("Unexpected retry of the invalid map: %v, %v.", reloaded, err)
+	}
+	assertView("after failed reload")
+
+	// Missing, i.e. being replaced: checked again later.
+	delete(fsys, "map.fdf")
+	if st, reloaded, err := render.ReloadIfChanged(g, last); err != nil || reloaded || st != last {
+		t.Errorf("Unexpected reload of a missing map: %v, %v.", reloaded, err)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Filter changes the heights of the map in place. Holes are left untouched.
+type Filter struct {
+	Name  string // Display name, with the parameters.
+	apply func(points [][]MapPoint)
+}
+
+// GaussianBlur smooths the heights, radius being the standard deviation in points.
+func GaussianBlur(radius float64) Filter {
+	return Filter{
+		Name: "blur " + strconv.FormatFloat(radius, 'g', -1, 64),
+		apply: func(points [][]MapPoint) {
+			if !(radius > 0) {
+				return
+			}
+			size := int(math.Ceil(3 * radius))
+			kernel := make([]float64, 2*size+1)
+			for i := range kernel {
+				d := float64(i - size)
+				kernel[i] = math.Exp(-d * d / (2 * radius * radius))
+			}
+
+			// Separable: horizontal then vertical pass.
+			// Only the points within the map, not holes, are weighted.
+			blurPass(points, kernel, func(x, y, d int) (int, int) { return x + d, y })
+			blurPass(points, kernel, func(x, y, d int) (int, int) { return x, y + d })
+		},
+	}
+}
+
+// blurPass convolves the heights with the kernel, along the direction given by step.
+func blurPass(points [][]MapPoint, kernel []float64, step func(x, y, d int) (int, int)) {
+	size := len(kernel) / 2
+	at := heightAt(points)
+	out := make([][]float64, len(points))
+	for y, line := range points {
+		out[y] = make([]float64, len(line))
+		for x, elem := range line {
+			if elem.IsHole() {
+				out[y][x] = elem.Z
+				continue
+			}
+			sum, weights := 0., 0.
+			for i, k := range kernel {
+				if z, ok := at(step(x, y, i-size)); ok {
+					sum, weights = sum+z*k, weights+k
+				}
+			}
+			out[y][x] = sum / weights
+		}
+	}
+	for y, line := range points {
+		for x := range line {
+			line[x].Z = out[y][x]
+		}
+	}
+}
+
+// heightAt returns a lookup of the height at x/y, false if out of the map or a hole.
+func heightAt(points [][]MapPoint) func(x, y int) (float64, bool) {
+	return func(x, y int) (float64, bool) {
+		if y < 0 || y >= len(points) || x < 0 || x >= len(points[y]) || points[y][x].IsHole() {
+			return 0, false
+		}
+		return points[y][x].Z, true
+	}
+}
+
+// Median replaces each height by the median of its (2*radius+1)^2 neighborhood, removing spikes.
+func Median(radius int) Filter {
+	return Filter{
+		Name: "median " + strconv.Itoa(radius),
+		apply: func(points [][]MapPoint) {
+			out := make([][]float64, len(points))
+			at := heightAt(points)
+			var window []float64
+			for y, line := range points {
+				out[y] = make([]float64, len(line))
+				for x, elem := range line {
+					out[y][x] = elem.Z
+					if elem.IsHole() {
+						continue
+					}
+					window = window[:0]
+					for dy := -radius; dy <= radius; dy++ {
+						for dx := -radius; dx <= radius; dx++ {
+							if z, ok := at(x+dx, y+dy); ok {
+								window = append(window, z)
+							}
+						}
+					}
+					slices.Sort(window)
+					if n := len(window); n%2 == 1 {
+						out[y][x] = window[n/2]
+					} else {
+						out[y][x] = (window[n/2-1] + window[n/2]) / 2
+					}
+				}
+			}
+			for y, line := range points {
+				for x := range line {
+					line[x].Z = out[y][x]
+				}
+			}
+		},
+	}
+}
+
+// Terrace quantizes the heights to the given number of evenly spaced levels,
+// from the lowest to the highest point.
+func Terrace(levels int) Filter {
+	return Filter{
+		Name: "terrace " + strconv.Itoa(levels),
+		apply: func(points [][]MapPoint) {
+			lo, hi := heightRange(points)
+			if levels < 2 || !(hi > lo) {
+				return
+			}
+			step := (hi - lo) / float64(levels-1)
+			mapHeights(points, func(z float64) float64 { return lo + math.Round((z-lo)/step)*step })
+		},
+	}
+}
+
+// Clamp limits the heights to the given range.
+func Clamp(lo, hi float64) Filter {
+	return Filter{
+		Name: "clamp " + formatRange(lo, hi),
+		apply: func(points [][]MapPoint) {
+			mapHeights(points, func(z float64) float64 { return math.Max(lo, math.Min(hi, z)) })
+		},
+	}
+}
+
+// Normalize linearly maps the heights from the lowest/highest point to the given range.
+// A flat map is moved to the low end.
+func Normalize(lo, hi float64) Filter {
+	return Filter{
+		Name: "normalize " + formatRange(lo, hi),
+		apply: func(points [][]MapPoint) {
+			minZ, maxZ := heightRange(points)
+			mapHeights(points, func(z float64) float64 {
+				if !(maxZ > minZ) {
+					return lo
+				}
+				return lo + (z-minZ)/(maxZ-minZ)*(hi-lo)
+			})
+		},
+	}
+}
+
+// mapHeights applies f to the height of each point, skipping the holes.
+func mapHeights(points [][]MapPoint, f func(float64) float64) {
+	for _, line := range points {
+		for x, elem := range line {
+			if !elem.IsHole() {
+				line[x].Z = f(elem.Z)
+			}
+		}
+	}
+}
+
+// formatRange formats lo,hi.
+func formatRange(lo, hi float64) string {
+	return strconv.FormatFloat(lo, 'g', -1, 64) + "," + strconv.FormatFloat(hi, 'g', -1, 64)
+}
+
+// parseRange parses "lo,hi".
+func parseRange(str string) (lo, hi float64, err error) {
+	loStr, hiStr, found := strings.Cut(str, ",")
+	if !found {
+		return 0, 0, fmt.Errorf("expected 'low,high'")
+	}
+	if lo, err = strconv.ParseFloat(strings.TrimSpace(loStr), 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid low: %w", err)
+	}
+	if hi, err = strconv.ParseFloat(strings.TrimSpace(hiStr), 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid high: %w", err)
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("low %v above high %v", lo, hi)
+	}
+	return lo, hi, nil
+}
+
+// activeFilter is a filter set on the engine, which can be toggled.
+type activeFilter struct {
+	Filter
+	enabled bool
+}
+
+// SetFilters sets the filters to apply to the heights, in order, all enabled.
+// Setting none restores the original heights.
+func (m *Fdf) SetFilters(filters ...Filter) {
+	m.filters = m.filters[:0]
+	for _, f := range filters {
+		m.filters = append(m.filters, activeFilter{Filter: f, enabled: true})
+	}
+	m.applyFilters()
+}
+
+// FilterNames returns the names of the filters set on the engine.
+func (m *Fdf) FilterNames() []string {
+	names := make([]string, 0, len(m.filters))
+	for _, f := range m.filters {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// FilterEnabled returns whether the i-th filter is enabled.
+func (m *Fdf) FilterEnabled(i int) bool { return i >= 0 && i < len(m.filters) && m.filters[i].enabled }
+
+// ToggleFilter enables/disables the i-th filter and re-applies the filters
+// from the original heights. Out of range indices are ignored.
+func (m *Fdf) ToggleFilter(i int) {
+	if i < 0 || i >= len(m.filters) {
+		return
+	}
+	m.filters[i].enabled = !m.filters[i].enabled
+	m.applyFilters()
+}
+
+// applyFilters restores the original heights and applies the enabled filters.
+// The original heights are only saved once a filter is set.
+func (m *Fdf) applyFilters() {
+	if m.origZ == nil {
+		if len(m.filters) == 0 {
+			return
+		}
+		m.origZ = make([][]float64, len(m.Points))
+		for y, line := range m.Points {
+			m.origZ[y] = make([]float64, len(line))
+			for x, elem := range line {
+				m.origZ[y][x] = elem.Z
+			}
+		}
+	}
+	m.restoreHeights()
+	for _, f := range m.filters {
+		if f.enabled {
+			f.apply(m.Points)
+		}
+	}
+}
+
+// restoreHeights sets the original heights back, if saved.
+func (m *Fdf) restoreHeights() {
+	for y, line := range m.origZ {
+		for x, z := range line {
+			m.Points[y][x].Z = z
+		}
+	}
+}
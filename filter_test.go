@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"go.creack.net/fdf/projection"
+)
+
+func TestFilters(t *testing.T) {
+	t.Parallel()
+
+	parse := func(str string) [][]MapPoint {
+		t.Helper()
+		m, err := parseMap(strings.NewReader(str), ParseOptions{})
+		if err != nil {
+			t.Fatalf("parseMap: %s.", err)
+		}
+		return m
+	}
+	const spike = "0 0 0 0 0\n0 0 0 0 0\n0 0 9 0 0\n0 0 0 0 0\n0 0 0 0 _\n"
+
+	m := parse(spike)
+	GaussianBlur(1).apply(m)
+	if z := m[2][2].Z; z <= 0 || z >= 9 || m[0][0].Z <= 0 || !m[4][4].IsHole() {
+		t.Fatalf("Unexpected blur: center %v, corner %v, hole %v.", z, m[0][0].Z, m[4][4].Z)
+	}
+
+	m = parse(spike)
+	Median(1).apply(m)
+	if lo, hi := heightRange(m); lo != 0 || hi != 0 || !m[4][4].IsHole() {
+		t.Fatalf("Median should remove the spike, got %v..%v.", lo, hi)
+	}
+
+	for _, tc := range []struct {
+		filter Filter
+		expect []float64
+	}{
+		{Terrace(3), []float64{0, 0, 5, 5, 10, 10}},
+		{Clamp(2, 6), []float64{2, 2, 4, 6, 6, 6}},
+		{Normalize(-1, 1), []float64{-1, -0.8, -0.2, 0.2, 0.6, 1}},
+	} {
+		m := parse("0 1 4 6 8 10\n")
+		tc.filter.apply(m)
+		for x, expect := range tc.expect {
+			if got := m[0][x].Z; math.Abs(got-expect) > 1e-9 {
+				t.Fatalf("Unexpected %s height at %d.\nGot:      %v\nExpected: %v", tc.filter.Name, x, got, expect)
+			}
+		}
+	}
+}
+
+func TestFdfFilters(t *testing.T) {
+	t.Parallel()
+
+	m, err := readMap(strings.NewReader("0 1 4 6 8 10\n0 1 4 6 8 10\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("readMap: %s.", err)
+	}
+	g := NewFdfFromMap(m, "test", LoadOptions{})
+	g.SetProjection(projection.NewDirect())
+
+	g.SetFilters(Clamp(2, 6), Terrace(2))
+	if got := g.Points[0][5].Z; got != 6 {
+		t.Fatalf("Unexpected filtered height %v.", got)
+	}
+
+	g.ToggleFilter(0)
+	if g.FilterEnabled(0) || !g.FilterEnabled(1) {
+		t.Fatal("Unexpected filter states.")
+	}
+	if got := g.Points[0][4].Z; got != 10 {
+		t.Fatalf("Unexpected height with the clamp disabled %v.", got)
+	}
+
+	// Transforms apply to the original heights, the filters being re-applied.
+	if err := g.Transform(FlipX()); err != nil {
+		t.Fatalf("Transform: %s.", err)
+	}
+	g.ToggleFilter(1)
+	if got := g.Points[0][1].Z; got != 8 {
+		t.Fatalf("Unexpected original height after transform %v.", got)
+	}
+
+	g.SetFilters()
+	if got := g.Points[0][0].Z; got != 10 {
+		t.Fatalf("Unexpected original height %v.", got)
+	}
+}
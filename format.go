@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// sniffLen is the number of bytes given to the sniff functions.
+const sniffLen = 512
+
+// gzipMagic starts any gzip stream.
+const gzipMagic = "\x1F\x8B"
+
+// DecodeFunc decodes a map from the given reader.
+type DecodeFunc func(r io.Reader, opts LoadOptions) (*Map, error)
+
+// SniffFunc reports whether the given header, the first bytes of the input,
+// looks like the format.
+type SniffFunc func(header []byte) bool
+
+// Format describes a map format.
+type Format struct {
+	Name string
+	Exts []string // Lower case file extensions, with the leading dot.
+
+	Sniff  SniffFunc // Optional.
+	Decode DecodeFunc
+}
+
+//nolint:gochecknoglobals // Expected global registry, guarded by the mutex.
+var (
+	formatsMu sync.RWMutex
+	formats   = []Format{
+		{Name: "fdf", Exts: []string{".fdf"}, Sniff: sniffFDF, Decode: decodeFDF},
+		{Name: "heightmap", Exts: []string{".png", ".jpg", ".jpeg", ".gif", ".pgm"}, Sniff: sniffHeightmap, Decode: decodeHeightmapFormat},
+		{Name: "asc", Exts: []string{".asc"}, Sniff: sniffASC, Decode: decodeASCFormat},
+		{Name: "xyz", Exts: []string{".xyz"}, Decode: decodeXYZFormat},
+	}
+)
+
+// RegisterFormat registers a map format.
+//
+// Formats registered last take precedence when looking up by extension or sniffing.
+// Registering an existing name replaces it.
+func RegisterFormat(name string, exts []string, sniff SniffFunc, decode DecodeFunc) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	formats = slices.DeleteFunc(formats, func(f Format) bool { return f.Name == name })
+	formats = append(formats, Format{Name: name, Exts: exts, Sniff: sniff, Decode: decode})
+}
+
+// Formats returns the registered formats, in registration order.
+func Formats() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	return slices.Clone(formats)
+}
+
+// lookupFormat finds the format for the given name, extension or header, in that order of preference.
+// Empty values are ignored.
+func lookupFormat(name, ext string, header []byte) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	if name != "" {
+		i := slices.IndexFunc(formats, func(f Format) bool { return f.Name == name })
+		if i < 0 {
+			return Format{}, false
+		}
+		return formats[i], true
+	}
+
+	// Walk backward so the last registered formats take precedence.
+	if ext != "" {
+		ext = strings.ToLower(ext)
+		for i := len(formats) - 1; i >= 0; i-- {
+			if slices.Contains(formats[i].Exts, ext) {
+				return formats[i], true
+			}
+		}
+	}
+	if len(header) > 0 {
+		for i := len(formats) - 1; i >= 0; i-- {
+			if formats[i].Sniff != nil && formats[i].Sniff(header) {
+				return formats[i], true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// isMapFile returns true if the given file name has a registered extension, optionally followed by .gz.
+func isMapFile(name string) bool {
+	_, ok := lookupFormat("", mapExt(name), nil)
+	return ok
+}
+
+// mapExt returns the extension of the given file name, ignoring the .gz one, i.e. ".fdf" for "map.fdf.gz".
+func mapExt(name string) string {
+	if ext := path.Ext(name); !strings.EqualFold(ext, ".gz") {
+		return ext
+	}
+	return path.Ext(name[:len(name)-len(".gz")])
+}
+
+// decodeMap decodes the map from r.
+//
+// Gzip compressed input is detected from its magic and decompressed transparently.
+//
+// The format is the one forced in the options if any, otherwise is looked up
+// from the name's extension then by sniffing the content.
+func decodeMap(r io.Reader, name string, opts LoadOptions) (*Map, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	// Peek errors are handled when decoding.
+	if magic, _ := br.Peek(len(gzipMagic)); string(magic) == gzipMagic {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer func() { _ = zr.Close() }() // Best effort, read only.
+		br = bufio.NewReaderSize(zr, 64*1024)
+	}
+
+	var header []byte
+	if opts.Format == "" && !isMapFile(name) {
+		header, _ = br.Peek(sniffLen)
+	}
+	f, ok := lookupFormat(opts.Format, mapExt(name), header)
+	if !ok {
+		if opts.Format != "" {
+			return nil, fmt.Errorf("unknown format %q", opts.Format)
+		}
+		return nil, fmt.Errorf("unknown format for %q", name)
+	}
+
+	m, err := f.Decode(br, opts)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", f.Name, err)
+	}
+	return m, nil
+}
+
+// decodeFDF implements DecodeFunc for .fdf maps.
+func decodeFDF(r io.Reader, opts LoadOptions) (*Map, error) {
+	m, err := readMap(r, opts.Parse)
+	if err != nil {
+		return nil, fmt.Errorf("readMap: %w", err)
+	}
+	return m, nil
+}
+
+// decodeHeightmapFormat implements DecodeFunc for heightmap images.
+func decodeHeightmapFormat(r io.Reader, opts LoadOptions) (*Map, error) {
+	points, err := decodeHeightmap(r, opts.Heightmap)
+	if err != nil {
+		return nil, fmt.Errorf("decodeHeightmap: %w", err)
+	}
+	return &Map{Points: points, CellX: 1, CellY: 1}, nil
+}
+
+// decodeASCFormat implements DecodeFunc for ESRI ASCII Grids.
+func decodeASCFormat(r io.Reader, _ LoadOptions) (*Map, error) { return decodeASC(r) }
+
+// decodeXYZFormat implements DecodeFunc for XYZ DEMs.
+func decodeXYZFormat(r io.Reader, _ LoadOptions) (*Map, error) { return decodeXYZ(r) }
+
+// sniffFDF checks that the first non blank, non comment, line looks like "height[,color]" elements.
+func sniffFDF(header []byte) bool {
+	// Skip the comments and header directives.
+	header = bytes.TrimLeft(header, "\n")
+	for isComment(header) {
+		_, rest, found := bytes.Cut(header, []byte{'\n'})
+		if !found { // Only comments so far.
+			return true
+		}
+		header = bytes.TrimLeft(rest, "\n")
+	}
+	line, _, found := bytes.Cut(header, []byte{'\n'})
+	fields := bytes.Fields(line)
+	if !found && len(fields) > 1 { // Truncated line, ignore the last, possibly partial, element.
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) == 0 {
+		return false
+	}
+	for _, elem := range fields {
+		h, _, _ := bytes.Cut(elem, []byte{','})
+		if _, err := parseHeight(string(h)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// sniffHeightmap checks the magic of the supported image formats.
+func sniffHeightmap(header []byte) bool {
+	for _, magic := range []string{"\x89PNG", "\xFF\xD8\xFF", "GIF8", "P2", "P5"} {
+		if bytes.HasPrefix(header, []byte(magic)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffASC checks that the content starts with an ESRI ASCII Grid header key.
+func sniffASC(header []byte) bool {
+	header = bytes.ToLower(bytes.TrimSpace(header))
+	return bytes.HasPrefix(header, []byte("ncols")) || bytes.HasPrefix(header, []byte("nrows"))
+}
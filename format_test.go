@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMapSniff(t *testing.T) {
+	t.Parallel()
+
+	for name, input := range map[string]string{
+		"fdf": "0 1 2,0xFF0000\n3 4 5\n",
+		"asc": "NCOLS 3\nNROWS 1\nCELLSIZE 2\n0 1 2\n",
+	} {
+		m, err := decodeMap(strings.NewReader(input), "stdin", LoadOptions{})
+		if err != nil {
+			t.Fatalf("decodeMap %s: %s.", name, err)
+		}
+		if len(m.Points[0]) != 3 {
+			t.Fatalf("Unexpected %s grid width %d.", name, len(m.Points[0]))
+		}
+	}
+
+	// All the embedded maps must be detected as .fdf from their content only.
+	entries, err := fs.ReadDir(mapData, "maps")
+	if err != nil {
+		t.Fatalf("ReadDir: %s.", err)
+	}
+	for _, elem := range entries {
+		buf, err := fs.ReadFile(mapData, "maps/"+elem.Name())
+		if err != nil {
+			t.Fatalf("ReadFile: %s.", err)
+		}
+		if f, ok := lookupFormat("", "", buf[:min(len(buf), sniffLen)]); !ok || f.Name != "fdf" {
+			t.Errorf("Unexpected format for %s: %q.", elem.Name(), f.Name)
+		}
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	t.Parallel()
+
+	// Single row of comma separated heights.
+	RegisterFormat("test-csv", []string{".test-csv"}, nil, func(r io.Reader, opts LoadOptions) (*Map, error) {
+		buf, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return decodeFDF(strings.NewReader(strings.ReplaceAll(string(buf), ",", " ")), opts)
+	})
+	t.Cleanup(func() { unregisterFormat("test-csv") })
+
+	m, err := decodeMap(strings.NewReader("1,2,3,4"), "map.TEST-CSV", LoadOptions{})
+	if err != nil {
+		t.Fatalf("decodeMap: %s.", err)
+	}
+	if len(m.Points[0]) != 4 {
+		t.Fatalf("Unexpected grid width %d.", len(m.Points[0]))
+	}
+
+	// Forced format.
+	if _, err := decodeMap(strings.NewReader("1,2,3,4"), "map.fdf", LoadOptions{Format: "test-csv"}); err != nil {
+		t.Fatalf("decodeMap with forced format: %s.", err)
+	}
+	if _, err := decodeMap(strings.NewReader("1 2"), "map.fdf", LoadOptions{Format: "unknown"}); err == nil {
+		t.Fatal("Expected error for unknown format.")
+	}
+}
+
+func TestDecodeMapGzip(t *testing.T) {
+	t.Parallel()
+
+	buf, err := fs.ReadFile(mapData, "maps/42.fdf")
+	if err != nil {
+		t.Fatalf("ReadFile: %s.", err)
+	}
+	expect, err := parseMap(bytes.NewReader(buf), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+
+	zbuf := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(zbuf)
+	if _, err := zw.Write(buf); err != nil {
+		t.Fatalf("gzip write: %s.", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip close: %s.", err)
+	}
+
+	// From the extension, ignoring .gz, and by sniffing the decompressed content.
+	for _, name := range []string{"42.fdf.gz", "42.FDF.GZ", "stdin"} {
+		m, err := decodeMap(bytes.NewReader(zbuf.Bytes()), name, LoadOptions{})
+		if err != nil {
+			t.Fatalf("decodeMap %s: %s.", name, err)
+		}
+		assertSameGrid(t, m.Points, expect)
+	}
+
+	if !isMapFile("42.fdf.gz") || isMapFile("42.gz") {
+		t.Fatal("Unexpected isMapFile result.")
+	}
+
+	if _, err := decodeMap(bytes.NewReader(zbuf.Bytes()[:zbuf.Len()/2]), "42.fdf.gz", LoadOptions{}); err == nil {
+		t.Fatal("Expected error for truncated gzip.")
+	}
+}
+
+// unregisterFormat removes the named format from the registry, for the tests not to leak.
+func unregisterFormat(name string) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	formats = slices.DeleteFunc(formats, func(f Format) bool { return f.Name == name })
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GenOptions controls the procedural map generation.
+type GenOptions struct {
+	// Algorithm is one of GenAlgorithms.
+	Algorithm string
+
+	Width, Height int
+
+	// Seed of the random algorithms. The same seed always gives the same map.
+	Seed int64
+
+	// MinHeight and MaxHeight is the range the heights are normalized to.
+	MinHeight, MaxHeight float64
+
+	// Octaves is the number of noise layers for perlin.
+	Octaves int
+
+	// Roughness is the amplitude ratio between two octaves for perlin,
+	// or between two subdivisions for diamond-square. Between 0 and 1.
+	Roughness float64
+
+	// Decimals is the number of decimals the heights are rounded to.
+	Decimals int
+}
+
+// DefaultGenOptions returns the default generation options.
+func DefaultGenOptions() GenOptions {
+	return GenOptions{
+		Algorithm: "perlin",
+		Width:     64,
+		Height:    64,
+		Seed:      1,
+		MaxHeight: 20,
+		Octaves:   6,
+		Roughness: 0.5,
+	}
+}
+
+// generator fills the given grid with raw heights, normalized afterward.
+type generator func(grid [][]MapPoint, opts GenOptions)
+
+// genAlgorithm is a generation algorithm.
+type genAlgorithm struct {
+	name   string
+	random bool // Whether the seed is used.
+	gen    generator
+}
+
+//nolint:gochecknoglobals // Expected "readonly" global.
+var genAlgorithms = []genAlgorithm{
+	{name: "diamond-square", random: true, gen: genDiamondSquare},
+	{name: "perlin", random: true, gen: genPerlin},
+	{name: "pyramid", gen: genShape(func(dx, dy float64) float64 { return 1 - math.Max(math.Abs(dx), math.Abs(dy)) })},
+	{name: "cone", gen: genShape(func(dx, dy float64) float64 { return math.Max(0, 1-math.Hypot(dx, dy)) })},
+	{name: "ripple", gen: genShape(func(dx, dy float64) float64 {
+		r := math.Hypot(dx, dy)
+		return math.Cos(r*3*2*math.Pi) * math.Exp(-2*r)
+	})},
+}
+
+// GenAlgorithms returns the names of the generation algorithms.
+func GenAlgorithms() []string {
+	names := make([]string, 0, len(genAlgorithms))
+	for _, elem := range genAlgorithms {
+		names = append(names, elem.name)
+	}
+	return names
+}
+
+// Generate creates a new map with the given algorithm.
+func Generate(opts GenOptions) (*Map, error) {
+	i := slices.IndexFunc(genAlgorithms, func(a genAlgorithm) bool { return a.name == opts.Algorithm })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown algorithm %q, expected one of %s", opts.Algorithm, strings.Join(GenAlgorithms(), ", "))
+	}
+	if opts.Width < 2 || opts.Height < 2 || opts.Width > maxImageSide || opts.Height > maxImageSide {
+		return nil, fmt.Errorf("invalid size %dx%d", opts.Width, opts.Height)
+	}
+	if opts.MinHeight > opts.MaxHeight {
+		return nil, fmt.Errorf("invalid height range %v..%v", opts.MinHeight, opts.MaxHeight)
+	}
+	if opts.Roughness < 0 || opts.Roughness > 1 {
+		return nil, fmt.Errorf("invalid roughness %v, expected between 0 and 1", opts.Roughness)
+	}
+	if opts.Decimals < 0 || opts.Decimals > maxGenDecimals {
+		return nil, fmt.Errorf("invalid decimals %d, expected between 0 and %d", opts.Decimals, maxGenDecimals)
+	}
+
+	grid := makeGrid(opts.Width, opts.Height)
+	genAlgorithms[i].gen(grid, opts)
+
+	// Normalize to the height range.
+	lo, hi := heightRange(grid)
+	scale := math.Pow(10, float64(opts.Decimals))
+	for _, line := range grid {
+		for x, elem := range line {
+			h := opts.MinHeight
+			if hi > lo {
+				h += float64((elem.Z - lo) / (hi - lo) * (opts.MaxHeight - opts.MinHeight))
+			}
+			line[x].Z = math.Round(h*scale) / scale
+		}
+	}
+
+	m := &Map{Points: grid, CellX: 1, CellY: 1}
+	m.Meta.Title = opts.Algorithm
+	if genAlgorithms[i].random {
+		m.Meta.Title += " #" + strconv.FormatInt(opts.Seed, 10)
+	}
+	return m, nil
+}
+
+// genShape creates a generator from the given function of the point's
+// position relative to the center, both between -1 and 1.
+func genShape(f func(dx, dy float64) float64) generator {
+	return func(grid [][]MapPoint, _ GenOptions) {
+		for y, line := range grid {
+			dy := 2*float64(y)/float64(len(grid)-1) - 1
+			for x := range line {
+				dx := 2*float64(x)/float64(len(line)-1) - 1
+				line[x].Z = f(dx, dy)
+			}
+		}
+	}
+}
+
+// genDiamondSquare runs the diamond-square algorithm on the smallest 2^n+1 square
+// fitting the grid, then crops it.
+func genDiamondSquare(grid [][]MapPoint, opts GenOptions) {
+	rng := rand.New(rand.NewSource(opts.Seed)) //nolint:gosec // Not for security.
+
+	size := 2
+	for size+1 < max(len(grid), len(grid[0])) {
+		size *= 2
+	}
+	size++
+	h := make([][]float64, size)
+	for y := range h {
+		h[y] = make([]float64, size)
+	}
+
+	// Random offset within the amplitude. The float64 conversions round the products,
+	// preventing the FMA fusion on some platforms, for the same seed to give the same map.
+	offset := func(amplitude float64) float64 { return float64((float64(rng.Float64()*2) - 1) * amplitude) }
+
+	last := size - 1
+	h[0][0], h[0][last], h[last][0], h[last][last] = rng.Float64(), rng.Float64(), rng.Float64(), rng.Float64()
+	amplitude := 1.
+	for step := last; step > 1; step /= 2 {
+		half := step / 2
+
+		// Diamond: center of each square.
+		for y := half; y < size; y += step {
+			for x := half; x < size; x += step {
+				avg := (h[y-half][x-half] + h[y-half][x+half] + h[y+half][x-half] + h[y+half][x+half]) / 4
+				h[y][x] = avg + offset(amplitude)
+			}
+		}
+
+		// Square: middle of each edge, averaging the neighbors within the grid.
+		for y := 0; y < size; y += half {
+			for x := (y/half%2 + 1) % 2 * half; x < size; x += step {
+				sum, n := 0., 0
+				for _, d := range [][2]int{{-half, 0}, {half, 0}, {0, -half}, {0, half}} {
+					if yy, xx := y+d[0], x+d[1]; yy >= 0 && yy < size && xx >= 0 && xx < size {
+						sum += h[yy][xx]
+						n++
+					}
+				}
+				h[y][x] = sum/float64(n) + offset(amplitude)
+			}
+		}
+
+		amplitude *= opts.Roughness
+	}
+
+	for y, line := range grid {
+		for x := range line {
+			line[x].Z = h[y][x]
+		}
+	}
+}
+
+// maxGenDecimals is the largest number of decimals, beyond float64 precision anyway.
+// Larger values overflow the rounding scale, turning all the heights into NaN.
+const maxGenDecimals = 15
+
+// perlinPeriods is the number of noise periods along the largest side, for the first octave.
+const perlinPeriods = 4
+
+// genPerlin sums octaves of 2D Perlin noise.
+func genPerlin(grid [][]MapPoint, opts GenOptions) {
+	rng := rand.New(rand.NewSource(opts.Seed)) //nolint:gosec // Not for security.
+
+	// Permutation table, doubled to avoid wrapping the indices.
+	var perm [512]int
+	for i, v := range rng.Perm(256) {
+		perm[i], perm[i+256] = v, v
+	}
+
+	side := float64(max(len(grid), len(grid[0])))
+	for y, line := range grid {
+		for x := range line {
+			freq, amplitude, sum := perlinPeriods/side, 1., 0.
+			for i := 0; i < max(opts.Octaves, 1); i++ {
+				sum += float64(amplitude * perlinNoise(&perm, float64(x)*freq, float64(y)*freq))
+				freq *= 2
+				amplitude *= opts.Roughness
+			}
+			line[x].Z = sum
+		}
+	}
+}
+
+// perlinNoise returns the 2D Perlin noise at the given position, between -1 and 1.
+func perlinNoise(perm *[512]int, x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	xi, yi := int(x0)&0xFF, int(y0)&0xFF
+	xf, yf := x-x0, y-y0
+
+	// Gradient dot product with the distance vector, 8 directions.
+	grad := func(hash int, dx, dy float64) float64 {
+		switch hash & 7 {
+		case 0:
+			return dx + dy
+		case 1:
+			return -dx + dy
+		case 2:
+			return dx - dy
+		case 3:
+			return -dx - dy
+		case 4:
+			return dx
+		case 5:
+			return -dx
+		case 6:
+			return dy
+		default:
+			return -dy
+		}
+	}
+	// The float64 conversions prevent the FMA fusion, see genDiamondSquare.
+	fade := func(t float64) float64 { return t * t * t * (float64(t*(float64(t*6)-15)) + 10) }
+	lerp := func(a, b, t float64) float64 { return a + float64(t*(b-a)) }
+
+	u, v := fade(xf), fade(yf)
+	aa, ab := perm[perm[xi]+yi], perm[perm[xi]+yi+1]
+	ba, bb := perm[perm[xi+1]+yi], perm[perm[xi+1]+yi+1]
+	return lerp(
+		lerp(grad(aa, xf, yf), grad(ba, xf-1, yf), u),
+		lerp(grad(ab, xf, yf-1), grad(bb, xf-1, yf-1), u),
+		v,
+	)
+}
+
+// parseSize parses "N" or "WxH".
+func parseSize(str string) (width, height int, err error) {
+	wStr, hStr, found := strings.Cut(strings.ToLower(str), "x")
+	if width, err = strconv.Atoi(wStr); err != nil {
+		return 0, 0, fmt.Errorf("invalid width: %w", err)
+	}
+	if !found {
+		return width, width, nil
+	}
+	if height, err = strconv.Atoi(hStr); err != nil {
+		return 0, 0, fmt.Errorf("invalid height: %w", err)
+	}
+	return width, height, nil
+}
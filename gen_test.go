@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	encode := func(opts GenOptions) string {
+		t.Helper()
+
+		m, err := Generate(opts)
+		if err != nil {
+			t.Fatalf("Generate %s: %s.", opts.Algorithm, err)
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := encodeMap(buf, m, EncodeOptions{}); err != nil {
+			t.Fatalf("encodeMap: %s.", err)
+		}
+		return buf.String()
+	}
+
+	// Pinned so the generated maps can be used as fixtures.
+	for algo, expect := range map[string]string{
+		"perlin":         "6862d42686c19ac5",
+		"diamond-square": "c08f66b8ed2910e0",
+	} {
+		opts := DefaultGenOptions()
+		opts.Algorithm, opts.Width, opts.Height, opts.Seed = algo, 16, 12, 42
+		sum := sha256.Sum256([]byte(encode(opts)))
+		if got := hex.EncodeToString(sum[:8]); got != expect {
+			t.Errorf("Unexpected %s map hash.\nGot:      %s\nExpected: %s", algo, got, expect)
+		}
+		if encode(opts) != encode(opts) {
+			t.Errorf("Same seed should give the same %s map.", algo)
+		}
+		seed2 := opts
+		seed2.Seed++
+		if encode(opts) == encode(seed2) {
+			t.Errorf("Different seeds should give different %s maps.", algo)
+		}
+	}
+
+	for _, algo := range GenAlgorithms() {
+		opts := DefaultGenOptions()
+		opts.Algorithm, opts.Width, opts.Height, opts.MinHeight, opts.MaxHeight, opts.Decimals = algo, 33, 20, -5, 5, 2
+		m, err := Generate(opts)
+		if err != nil {
+			t.Fatalf("Generate %s: %s.", algo, err)
+		}
+		if len(m.Points) != 20 || len(m.Points[0]) != 33 {
+			t.Fatalf("Unexpected %s size %dx%d.", algo, len(m.Points[0]), len(m.Points))
+		}
+		if lo, hi := heightRange(m.Points); lo != -5 || hi != 5 {
+			t.Fatalf("Unexpected %s height range %v..%v.", algo, lo, hi)
+		}
+	}
+
+	if _, err := Generate(GenOptions{Algorithm: "unknown", Width: 10, Height: 10}); err == nil {
+		t.Fatal("Expected error for unknown algorithm.")
+	}
+	for _, decimals := range []int{-1, maxGenDecimals + 1, 400} {
+		opts := DefaultGenOptions()
+		opts.Decimals = decimals
+		if _, err := Generate(opts); err == nil {
+			t.Errorf("Expected error for %d decimals.", decimals)
+		}
+	}
+}
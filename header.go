@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.creack.net/fdf/math3"
+	"go.creack.net/fdf/projection"
+)
+
+// MapMeta holds the optional viewing settings of a map, from the .fdf header.
+//
+// The header is a set of "# key: value" lines before the first row:
+//
+//	# title: Pylone
+//	# cell: 10 20
+//	# height-factor: 0.5
+//	# projection: iso 1 0 0.5 20
+//	# palette: terrain
+//
+// The projection takes the optional camera angles (x, y, z) and scale.
+// The palette is a ramp name or a color list, see ParseRamp.
+// As they start with '#', other readers can skip them as comments.
+type MapMeta struct {
+	Title        string
+	HeightFactor float64         // 0 when unset.
+	View         projection.View // Default camera.
+	Palette      string          // Ramp name or color list, see ParseRamp. Only applied to the points without explicit color.
+}
+
+// errUnknownDirective is returned for the '#' lines which are not directives, i.e. comments.
+var errUnknownDirective = errors.New("unknown directive")
+
+// parseDirective parses the given header line, without the leading '#', into m.
+func parseDirective(m *Map, line string) error {
+	key, value, found := strings.Cut(line, ":")
+	if !found {
+		return errUnknownDirective
+	}
+	key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+	fields := strings.Fields(value)
+
+	switch key {
+	case "title":
+		m.Meta.Title = value
+	case "cell":
+		cells, err := parsePositiveFloats(fields)
+		if err != nil || len(cells) < 1 || len(cells) > 2 {
+			return fmt.Errorf("invalid cell %q, expected 'x [y]'", value)
+		}
+		m.CellX, m.CellY = cells[0], cells[len(cells)-1]
+	case "height-factor":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f == 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("invalid height factor %q", value)
+		}
+		m.Meta.HeightFactor = f
+	case "projection":
+		view, err := parseView(fields)
+		if err != nil {
+			return fmt.Errorf("invalid projection %q: %w", value, err)
+		}
+		m.Meta.View = view
+	case "palette":
+		if _, err := ParseRamp(value); err != nil {
+			return err
+		}
+		m.Meta.Palette = value
+	default:
+		return errUnknownDirective
+	}
+	return nil
+}
+
+// parseView parses "iso [x y z] [scale]".
+func parseView(fields []string) (projection.View, error) {
+	var view projection.View
+	if len(fields) == 0 || fields[0] != "iso" {
+		return view, fmt.Errorf("only 'iso' is supported")
+	}
+	fields = fields[1:]
+
+	var nums []float64
+	for _, elem := range fields {
+		f, err := strconv.ParseFloat(elem, 64)
+		if err != nil {
+			return view, fmt.Errorf("parse float: %w", err)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return view, fmt.Errorf("non-finite value %q", elem)
+		}
+		nums = append(nums, f)
+	}
+	if len(nums) >= 3 {
+		view.Angle = &math3.Vec{X: nums[0], Y: nums[1], Z: nums[2]}
+		nums = nums[3:]
+	}
+	switch len(nums) {
+	case 0:
+	case 1:
+		if nums[0] < 1 || nums[0] > math.MaxInt32 || nums[0] != math.Trunc(nums[0]) {
+			return view, fmt.Errorf("invalid scale %v, expected a positive integer", nums[0])
+		}
+		view.Scale = int(nums[0])
+	default:
+		return view, fmt.Errorf("expected 'iso [x y z] [scale]'")
+	}
+	return view, nil
+}
+
+// parsePositiveFloats parses the given fields, all strictly positive.
+func parsePositiveFloats(fields []string) ([]float64, error) {
+	out := make([]float64, 0, len(fields))
+	for _, elem := range fields {
+		f, err := strconv.ParseFloat(elem, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse float: %w", err)
+		}
+		if !(f > 0) {
+			return nil, fmt.Errorf("%v is not positive", f)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// writeMeta writes the header directives of the map, skipping the unset ones.
+func writeMeta(bw *bufio.Writer, m *Map) {
+	// Errors are sticky, checked on flush.
+	if m.Meta.Title != "" {
+		_, _ = fmt.Fprintf(bw, "# title: %s\n", strings.Join(strings.Fields(m.Meta.Title), " "))
+	}
+	if (m.CellX != 1 || m.CellY != 1) && m.CellX > 0 && m.CellY > 0 {
+		buf := append([]byte("# cell: "), strconv.FormatFloat(m.CellX, 'g', -1, 64)...)
+		if m.CellY != m.CellX {
+			buf = append(buf, ' ')
+			buf = strconv.AppendFloat(buf, m.CellY, 'g', -1, 64)
+		}
+		_, _ = bw.Write(append(buf, '\n'))
+	}
+	if m.Meta.HeightFactor != 0 {
+		_, _ = fmt.Fprintf(bw, "# height-factor: %s\n", strconv.FormatFloat(m.Meta.HeightFactor, 'g', -1, 64))
+	}
+	if view := m.Meta.View; view.Angle != nil || view.Scale > 0 {
+		buf := []byte("# projection: iso")
+		if view.Angle != nil {
+			for _, f := range []float64{view.Angle.X, view.Angle.Y, view.Angle.Z} {
+				buf = append(buf, ' ')
+				buf = strconv.AppendFloat(buf, f, 'g', -1, 64)
+			}
+		}
+		if view.Scale > 0 {
+			buf = append(buf, ' ')
+			buf = strconv.AppendInt(buf, int64(view.Scale), 10)
+		}
+		_, _ = bw.Write(append(buf, '\n'))
+	}
+	if m.Meta.Palette != "" {
+		_, _ = fmt.Fprintf(bw, "# palette: %s\n", m.Meta.Palette)
+	}
+}
+
+// isComment returns true if the given .fdf line is a comment or header directive.
+func isComment(line []byte) bool { return bytes.HasPrefix(line, []byte{'#'}) }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.creack.net/fdf/projection"
+)
+
+func TestReadMapHeader(t *testing.T) {
+	t.Parallel()
+
+	const input = `# title: Test map
+# Not a directive.
+# cell: 2 3
+# height-factor: 0.5
+# projection: iso 1 0 0.5 20
+# palette: terrain
+0 1
+# title: Ignored after the first row.
+2 3,0xFF0000
+`
+	m, err := readMap(strings.NewReader(input), ParseOptions{})
+	if err != nil {
+		t.Fatalf("readMap: %s.", err)
+	}
+	if m.Meta.Title != "Test map" || m.CellX != 2 || m.CellY != 3 || m.Meta.HeightFactor != 0.5 || m.Meta.Palette != "terrain" {
+		t.Fatalf("Unexpected header: %+v.", m)
+	}
+	if v := m.Meta.View; v.Angle == nil || v.Angle.Z != 0.5 || v.Scale != 20 {
+		t.Fatalf("Unexpected view: %+v.", v)
+	}
+	if len(m.Points) != 2 {
+		t.Fatalf("Unexpected row count %d.", len(m.Points))
+	}
+
+	g := &Fdf{projection: projection.NewDirect(), heightFactor: 1}
+	g.setMap(m)
+	if name := g.CurrentMapName(); name != "Test map" {
+		t.Fatalf("Unexpected map name %q.", name)
+	}
+	if g.heightFactor != 0.5 {
+		t.Fatalf("Unexpected height factor %v.", g.heightFactor)
+	}
+	// The ramp only applies to the points without color.
+	lo, hi := g.colorRange()
+	if c := g.pointColor(m.Points[1][1], lo, hi); c != m.Points[1][1].color {
+		t.Fatalf("Unexpected explicit color %v.", c)
+	}
+	if c, expect := g.pointColor(m.Points[0][0], lo, hi), ramps["terrain"][0]; c != expect {
+		t.Fatalf("Unexpected ramp color.\nGot:      %v\nExpected: %v", c, expect)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := encodeMap(buf, g.Map(), EncodeOptions{}); err != nil {
+		t.Fatalf("encodeMap: %s.", err)
+	}
+	m2, err := readMap(buf, ParseOptions{})
+	if err != nil {
+		t.Fatalf("readMap encoded: %s.", err)
+	}
+	if m2.Meta.Title != m.Meta.Title || m2.CellY != 3 || m2.Meta.View.Scale != 20 || m2.Meta.Palette != "terrain" {
+		t.Fatalf("Unexpected encoded header: %+v.", m2)
+	}
+	assertSameGrid(t, m2.Points, m.Points)
+
+	// Invalid directives are comments, reported as warnings.
+	var warnings []*ParseError
+	const invalid = `# cell: 0
+# palette: the default one
+# height-factor: NaN
+# height-factor: -Inf
+# projection: iso 20.9
+# projection: iso 0 Inf 0
+1 2
+`
+	m, err = readMap(strings.NewReader(invalid), ParseOptions{Warn: func(err *ParseError) { warnings = append(warnings, err) }})
+	if err != nil {
+		t.Fatalf("readMap with invalid directives: %s.", err)
+	}
+	if m.CellX != 1 || m.Meta.Palette != "" || m.Meta.HeightFactor != 0 || m.Meta.View.Scale != 0 || m.Meta.View.Angle != nil {
+		t.Fatalf("Unexpected header from invalid directives: %+v.", m)
+	}
+	if len(warnings) != 6 {
+		t.Fatalf("Unexpected warnings.\nGot:      %v\nExpected: lines 1 to 6", warnings)
+	}
+	for i, elem := range warnings {
+		if elem.Line != i+1 {
+			t.Errorf("Unexpected warning line.\nGot:      %d\nExpected: %d", elem.Line, i+1)
+		}
+	}
+}
+
+func TestSetMapHeightFactor(t *testing.T) {
+	t.Parallel()
+
+	withHeader := &Map{Points: [][]MapPoint{{{}, {}}}, Meta: MapMeta{HeightFactor: 0.5}}
+	without := &Map{Points: [][]MapPoint{{{}, {}}}}
+
+	// The header's is not kept for the next map.
+	g := &Fdf{projection: projection.NewDirect(), heightFactor: 1}
+	g.setMap(withHeader)
+	g.setMap(without)
+	if g.heightFactor != 1 {
+		t.Errorf("Unexpected height factor without header.\nGot:      %v\nExpected: 1", g.heightFactor)
+	}
+
+	// The default overrides the headers.
+	g.SetDefaultHeightFactor(2)
+	for _, elem := range []*Map{withHeader, without} {
+		g.setMap(elem)
+		if g.heightFactor != 2 {
+			t.Errorf("Unexpected overridden height factor.\nGot:      %v\nExpected: 2", g.heightFactor)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // Register the decoder for image.Decode.
+	_ "image/jpeg" // Register the decoder for image.Decode.
+	_ "image/png"  // Register the decoder for image.Decode.
+	"io"
+	"math"
+)
+
+// maxImageSide is the largest width/height accepted for heightmap images.
+const maxImageSide = 1 << 15
+
+// HeightmapOptions controls how images are converted to maps.
+type HeightmapOptions struct {
+	// MinHeight and MaxHeight are the heights of the darkest and brightest pixels.
+	MinHeight, MaxHeight float64
+
+	// Step downsamples the image by averaging blocks of Step x Step pixels.
+	// MaxSize increases the step if needed so neither dimension of the map exceeds it.
+	// 0 disables either.
+	Step, MaxSize int
+
+	// Color uses the pixel colors as point colors instead of the default one.
+	Color bool
+}
+
+// DefaultHeightmapOptions returns the default heightmap options.
+func DefaultHeightmapOptions() HeightmapOptions {
+	return HeightmapOptions{
+		MinHeight: 0,
+		MaxHeight: 20,
+		Step:      1,
+		MaxSize:   512,
+	}
+}
+
+// step returns the effective downsampling step for the given image size.
+func (o HeightmapOptions) step(width, height int) int {
+	step := max(o.Step, 1)
+	if o.MaxSize > 0 {
+		step = max(step, (max(width, height)+o.MaxSize-1)/o.MaxSize)
+	}
+	return step
+}
+
+// decodeImage decodes the image after checking the size from its header,
+// so a small file declaring huge dimensions is rejected before allocating the pixels.
+func decodeImage(r io.Reader) (image.Image, error) {
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if cfg.Width > maxImageSide || cfg.Height > maxImageSide {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return img, nil
+}
+
+// decodeHeightmap decodes a grayscale heightmap image (PNG/JPEG/GIF/PGM)
+// into a map. The luminance of each pixel is used as the height.
+func decodeHeightmap(r io.Reader, opts HeightmapOptions) ([][]MapPoint, error) {
+	br := bufio.NewReader(r)
+
+	var (
+		img image.Image
+		err error
+	)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte("P2")) || bytes.Equal(magic, []byte("P5")) {
+		img, err = decodePGM(br)
+	} else {
+		img, err = decodeImage(br)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("no points")
+	}
+
+	step := opts.step(bounds.Dx(), bounds.Dy())
+	width := (bounds.Dx() + step - 1) / step
+	height := (bounds.Dy() + step - 1) / step
+
+	m := makeGrid(width, height)
+	for y, line := range m {
+		for x := range line {
+			// Average the block of pixels.
+			block := image.Rect(x*step, y*step, (x+1)*step, (y+1)*step).Add(bounds.Min).Intersect(bounds)
+			lum, col := averageBlock(img, block)
+
+			line[x].Z = opts.MinHeight + lum*(opts.MaxHeight-opts.MinHeight)
+			if opts.Color {
+				line[x].color, line[x].explicit = col, true
+			}
+		}
+	}
+	return m, nil
+}
+
+// averageBlock returns the average luminance, between 0 and 1, and the average color of the given block.
+func averageBlock(img image.Image, block image.Rectangle) (float64, color.RGBA) {
+	var lum, r, g, b float64
+	for y := block.Min.Y; y < block.Max.Y; y++ {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			lum += float64(luminance(img, x, y))
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r, g, b = r+float64(cr), g+float64(cg), b+float64(cb)
+		}
+	}
+	n := float64(block.Dx() * block.Dy())
+	toUint8 := func(v float64) uint8 { return uint8(math.Round(v / n / 0xFFFF * 0xFF)) }
+	return lum / n / 0xFFFF, color.RGBA{R: toUint8(r), G: toUint8(g), B: toUint8(b), A: 0xFF}
+}
+
+// luminance returns the 16 bits luminance of the given pixel.
+// Grayscale images are read directly to keep their full precision.
+func luminance(img image.Image, x, y int) uint16 {
+	switch img := img.(type) {
+	case *image.Gray16:
+		return img.Gray16At(x, y).Y
+	case *image.Gray:
+		return uint16(img.GrayAt(x, y).Y) * 0x101
+	default:
+		return color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y //nolint:forcetypeassert // Guaranteed by the model.
+	}
+}
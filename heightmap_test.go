@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDecodeHeightmapPGM(t *testing.T) {
+	t.Parallel()
+
+	const pgm = "P2\n# 16 bits plain pgm.\n4 2\n65535\n0 65535 32768 1\n65535 65535 0 0\n"
+
+	opts := HeightmapOptions{MinHeight: -10, MaxHeight: 10}
+	got, err := decodeHeightmap(strings.NewReader(pgm), opts)
+	if err != nil {
+		t.Fatalf("decodeHeightmap: %s.", err)
+	}
+	if len(got) != 2 || len(got[0]) != 4 {
+		t.Fatalf("Unexpected grid size %dx%d.", len(got[0]), len(got))
+	}
+	// 16 bits precision must be preserved: 1/65535 is not 0.
+	if got[0][0].Z != -10 || got[0][1].Z != 10 || got[0][3].Z == -10 {
+		t.Fatalf("Unexpected heights: %v, %v, %v.", got[0][0].Z, got[0][1].Z, got[0][3].Z)
+	}
+
+	// Downsample by 2: average of 2x2 blocks.
+	opts.Step = 2
+	got, err = decodeHeightmap(strings.NewReader(pgm), opts)
+	if err != nil {
+		t.Fatalf("decodeHeightmap: %s.", err)
+	}
+	if len(got) != 1 || len(got[0]) != 2 {
+		t.Fatalf("Unexpected downsampled grid size %dx%d.", len(got[0]), len(got))
+	}
+	if expect := 5.; got[0][0].Z != expect {
+		t.Fatalf("Unexpected downsampled height.\nGot:      %v\nExpected: %v", got[0][0].Z, expect)
+	}
+}
+
+//nolint:paralleltest // Measures the allocated memory, alone.
+func TestDecodePGMTruncated(t *testing.T) {
+	// The header claims 2 GiB of pixels, only a few bytes follow.
+	const pgm = "P5\n32768 32768\n65535\n\x00\x01\x02"
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := decodePGM(strings.NewReader(pgm)); err == nil {
+		t.Error("Expected error for truncated image.")
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("Unexpected allocation for a truncated image: %d bytes.", n)
+	}
+}
+
+//nolint:paralleltest // Measures the allocated memory, alone.
+func TestDecodeHeightmapHugePNG(t *testing.T) {
+	// Encode a 1x1 png and patch its header to declare 100000x100000 pixels.
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("Encode: %s.", err)
+	}
+	data := buf.Bytes()
+	// Signature (8), length (4), "IHDR" (4), then width and height.
+	ihdr := data[12 : 12+4+13]
+	binary.BigEndian.PutUint32(ihdr[4:], 100000)
+	binary.BigEndian.PutUint32(ihdr[8:], 100000)
+	binary.BigEndian.PutUint32(data[12+4+13:], crc32.ChecksumIEEE(ihdr))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := decodeHeightmap(bytes.NewReader(data), DefaultHeightmapOptions())
+	runtime.ReadMemStats(&after)
+	if err == nil || !strings.Contains(err.Error(), "image too large") {
+		t.Errorf("Unexpected error.\nGot:      %v\nExpected: image too large", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("Unexpected allocation for a huge image: %d bytes.", n)
+	}
+}
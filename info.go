@@ -0,0 +1,205 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// MapInfo holds the statistics of a map and the issues found in it.
+type MapInfo struct {
+	Name string `json:"name"`
+
+	// Width is the number of points of the widest row.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	Points int `json:"points"` // Including the holes.
+	Holes  int `json:"holes"`
+
+	// Heights is nil if the map only has holes.
+	Heights   *HeightStats   `json:"heights,omitempty"`
+	Histogram []HistogramBin `json:"histogram,omitempty"`
+
+	// Colors lists the colors by decreasing usage.
+	Colors []ColorUsage `json:"colors"`
+
+	// IrregularRows lists the rows not having the points of the first row.
+	IrregularRows []RowLength `json:"irregularRows,omitempty"`
+
+	Warnings []string `json:"warnings,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// HeightStats are the height statistics of a map, ignoring the holes.
+type HeightStats struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+// HistogramBin is the number of points with a height in [From, To).
+// The last bin includes To.
+type HistogramBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// ColorUsage is the number of points having a color.
+type ColorUsage struct {
+	Color string `json:"color"` // 0xRRGGBB, or 0xRRGGBBAA if not opaque.
+	Count int    `json:"count"`
+}
+
+// RowLength is the number of points of a row, 1-based.
+type RowLength struct {
+	Row    int `json:"row"`
+	Points int `json:"points"`
+}
+
+// Inspect computes the statistics of the map, with a height histogram of the
+// given number of bins, and warns about the suspicious things: ragged rows,
+// flat maps, maps with a single row/column or only holes.
+func Inspect(m *Map, name string, bins int) MapInfo {
+	info := MapInfo{Name: name, Height: len(m.Points), Colors: []ColorUsage{}}
+
+	sum := 0.
+	colors := map[color.RGBA]int{}
+	for y, line := range m.Points {
+		info.Width = max(info.Width, len(line))
+		if len(line) != len(m.Points[0]) {
+			info.IrregularRows = append(info.IrregularRows, RowLength{Row: y + 1, Points: len(line)})
+		}
+		for _, elem := range line {
+			info.Points++
+			if elem.IsHole() {
+				info.Holes++
+				continue
+			}
+			sum += elem.Z
+			colors[elem.color]++
+		}
+	}
+
+	for c, n := range colors {
+		info.Colors = append(info.Colors, ColorUsage{Color: string(appendHexColor(nil, c)), Count: n})
+	}
+	slices.SortFunc(info.Colors, func(a, b ColorUsage) int {
+		if n := cmp.Compare(b.Count, a.Count); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Color, b.Color)
+	})
+
+	if n := info.Points - info.Holes; n > 0 {
+		lo, hi := heightRange(m.Points)
+		info.Heights = &HeightStats{Min: lo, Max: hi, Mean: sum / float64(n)}
+		info.Histogram = histogram(m.Points, lo, hi, bins)
+	}
+
+	switch {
+	case info.Heights == nil:
+		info.Warnings = append(info.Warnings, "no heights, all the points are holes")
+	case info.Heights.Min == info.Heights.Max:
+		info.Warnings = append(info.Warnings, fmt.Sprintf("flat map, all the heights are %v", info.Heights.Min))
+	}
+	if info.Width < 2 || info.Height < 2 {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("%dx%d map, at least 2x2 points are needed to draw a surface", info.Width, info.Height))
+	}
+	if n := len(info.IrregularRows); n > 0 {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("ragged rows: %d rows don't have the %d points of the first row", n, len(m.Points[0])))
+	}
+	return info
+}
+
+// histogram counts the heights in bins evenly spaced between lo and hi.
+// A flat map has a single bin.
+func histogram(points [][]MapPoint, lo, hi float64, bins int) []HistogramBin {
+	if bins < 1 || !(hi > lo) {
+		bins = 1
+	}
+	step := (hi - lo) / float64(bins)
+	out := make([]HistogramBin, bins)
+	for i := range out {
+		out[i].From, out[i].To = lo+float64(i)*step, lo+float64(i+1)*step
+	}
+	out[bins-1].To = hi // Avoid rounding errors.
+	for _, line := range points {
+		for _, elem := range line {
+			if elem.IsHole() {
+				continue
+			}
+			i := bins - 1
+			if step > 0 {
+				i = min(int((elem.Z-lo)/step), bins-1)
+			}
+			out[i].Count++
+		}
+	}
+	return out
+}
+
+const (
+	// histogramWidth is the width of the longest histogram bar, in characters.
+	histogramWidth = 40
+
+	// maxListed is the number of colors/rows listed in the human readable form.
+	maxListed = 10
+)
+
+// write prints the information in a human readable form.
+func (info MapInfo) write(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Map:      %s\n", info.Name)
+	if len(info.Errors) == 0 {
+		fmt.Fprintf(&sb, "Size:     %dx%d\n", info.Width, info.Height)
+		fmt.Fprintf(&sb, "Points:   %d, %d holes.\n", info.Points, info.Holes)
+	}
+	if info.Heights != nil {
+		fmt.Fprintf(&sb, "Heights:  min %g, max %g, mean %.4g\n", info.Heights.Min, info.Heights.Max, info.Heights.Mean)
+	}
+	if len(info.Histogram) > 0 {
+		sb.WriteString("Histogram:\n")
+		top := 0
+		for _, elem := range info.Histogram {
+			top = max(top, elem.Count)
+		}
+		for _, elem := range info.Histogram {
+			bar := strings.Repeat("#", int(math.Ceil(float64(elem.Count*histogramWidth)/float64(max(top, 1)))))
+			fmt.Fprintf(&sb, "  %10.4g .. %-10.4g %8d %s\n", elem.From, elem.To, elem.Count, bar)
+		}
+	}
+	if len(info.Colors) > 0 {
+		sb.WriteString("Colors:\n")
+		for _, elem := range info.Colors[:min(len(info.Colors), maxListed)] {
+			fmt.Fprintf(&sb, "  %-10s %8d\n", elem.Color, elem.Count)
+		}
+		if n := len(info.Colors) - maxListed; n > 0 {
+			fmt.Fprintf(&sb, "  ... and %d more.\n", n)
+		}
+	}
+	if len(info.IrregularRows) > 0 {
+		sb.WriteString("Irregular rows (row: points):\n")
+		for _, elem := range info.IrregularRows[:min(len(info.IrregularRows), maxListed)] {
+			fmt.Fprintf(&sb, "  %d: %d\n", elem.Row, elem.Points)
+		}
+		if n := len(info.IrregularRows) - maxListed; n > 0 {
+			fmt.Fprintf(&sb, "  ... and %d more.\n", n)
+		}
+	}
+	for _, elem := range info.Warnings {
+		fmt.Fprintf(&sb, "Warning:  %s.\n", elem)
+	}
+	for _, elem := range info.Errors {
+		fmt.Fprintf(&sb, "Error:    %s.\n", elem)
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
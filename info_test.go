@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	var warnings []string
+	m, err := readMap(strings.NewReader("0 2 _,0xFF0000\n4,0xFF0000 6\n8 10 10 10,0x00FF00FF\n"), ParseOptions{
+		Warn: func(err *ParseError) { warnings = append(warnings, err.Error()) },
+	})
+	if err != nil {
+		t.Fatalf("readMap: %s.", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Unexpected parse warnings.\nGot:      %q\nExpected: none", warnings)
+	}
+
+	info := Inspect(m, "test", 2)
+	if info.Width != 4 || info.Height != 3 || info.Points != 9 || info.Holes != 1 {
+		t.Errorf("Unexpected size.\nGot:      %dx%d, %d points, %d holes\nExpected: 4x3, 9 points, 1 holes", info.Width, info.Height, info.Points, info.Holes)
+	}
+	if got, expect := *info.Heights, (HeightStats{Min: 0, Max: 10, Mean: 50. / 8}); got != expect {
+		t.Errorf("Unexpected heights.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if got, expect := info.Histogram, []HistogramBin{{0, 5, 3}, {5, 10, 5}}; !slices.Equal(got, expect) {
+		t.Errorf("Unexpected histogram.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if got, expect := info.Colors, []ColorUsage{{"0xFFFFFF", 6}, {"0x00FF00", 1}, {"0xFF0000", 1}}; !slices.Equal(got, expect) {
+		t.Errorf("Unexpected colors.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if got, expect := info.IrregularRows, []RowLength{{Row: 2, Points: 2}, {Row: 3, Points: 4}}; !slices.Equal(got, expect) {
+		t.Errorf("Unexpected irregular rows.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if len(info.Warnings) != 1 || !strings.HasPrefix(info.Warnings[0], "ragged rows") {
+		t.Errorf("Unexpected warnings.\nGot:      %q\nExpected: the ragged rows", info.Warnings)
+	}
+
+	for name, tc := range map[string]struct {
+		input, warning string
+	}{
+		"flat":       {"1 1\n1 1\n", "flat map"},
+		"single row": {"1 2 3\n", "3x1 map"},
+		"holes only": {"_ _\n_ _\n", "no heights"},
+	} {
+		m, err := readMap(strings.NewReader(tc.input), ParseOptions{})
+		if err != nil {
+			t.Fatalf("readMap %s: %s.", name, err)
+		}
+		info := Inspect(m, name, 10)
+		if !slices.ContainsFunc(info.Warnings, func(w string) bool { return strings.Contains(w, tc.warning) }) {
+			t.Errorf("Unexpected warnings for %s.\nGot:      %q\nExpected: %q", name, info.Warnings, tc.warning)
+		}
+	}
+}
@@ -6,21 +6,68 @@ import (
 	"math"
 )
 
-// drawLine from p0 to p1.
-func drawLine(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color) {
+// drawLine from p0 to p1, the colors being interpolated in the given space.
+// Translucent colors are composited over the existing pixels.
+// width is the thickness of the line in pixels, across its major axis, centered on it.
+// As the pixels can't be split, even widths cover the same pixels as drawLineAA:
+// the half covered ones on both sides are drawn, i.e. 2 draws as 3.
+func drawLine(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color, space ColorSpace, width int) {
 	rect := image.Rectangle{Min: p0, Max: p1}.Canon()
 	if rect.Dx() > rect.Dy() {
-		drawLineHoriz(dst, p0, p1, col1, col2)
+		drawLineHoriz(dst, p0, p1, col1, col2, space, width)
 	} else {
-		drawLineVert(dst, p0, p1, col1, col2)
+		drawLineVert(dst, p0, p1, col1, col2, space, width)
+	}
+}
+
+// drawLineAA from p0 to p1 like drawLine, anti-aliased with Xiaolin Wu's algorithm:
+// each step along the major axis covers the 2 pixels straddling the line, weighted by their distance to it.
+func drawLineAA(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color, space ColorSpace, width int) {
+	// Work along the X axis, swapping the coordinates of steep lines.
+	steep := abs(p1.Y-p0.Y) > abs(p1.X-p0.X)
+	if steep {
+		p0, p1 = image.Pt(p0.Y, p0.X), image.Pt(p1.Y, p1.X)
+	}
+	// Start with the lowest point, swap if needed, along with the colors.
+	if p1.X < p0.X {
+		p0, p1 = p1, p0
+		col1, col2 = col2, col1
+	}
+
+	dx, dy := p1.X-p0.X, p1.Y-p0.Y
+	slope := 0.
+	if dx != 0 {
+		slope = float64(dy) / float64(dx)
+	}
+
+	plot := func(x, y int, c color.RGBA, coverage float64) {
+		if steep {
+			x, y = y, x
+		}
+		blendPixel(dst, x, y, scaleAlpha(c, coverage))
+	}
+	for i := 0; i <= dx; i++ {
+		position := 1.
+		if dx != 0 {
+			position = float64(i) / float64(dx)
+		}
+		c := getGradientColor(col1, col2, position, space)
+
+		// Cover [lo, hi) across the line, the pixel y spanning [y, y+1).
+		lo := float64(p0.Y) + slope*float64(i) - float64(max(width, 1)-1)/2
+		hi := lo + float64(max(width, 1))
+		for y := int(math.Floor(lo)); float64(y) < hi; y++ {
+			plot(p0.X+i, y, c, math.Min(float64(y+1), hi)-math.Max(float64(y), lo))
+		}
 	}
 }
 
 // drawLineHoriz along the X axis.
-func drawLineHoriz(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color) {
-	// Start with the lowest point, swap if needed.
+func drawLineHoriz(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color, space ColorSpace, width int) {
+	// Start with the lowest point, swap if needed, along with the colors.
 	if p1.X-p0.X < 0 {
 		p0, p1 = p1, p0
+		col1, col2 = col2, col1
 	}
 
 	// Dims.
@@ -35,7 +82,10 @@ func drawLineHoriz(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color)
 
 	d := 2*dy - dx
 	for x, y := p0.X, p0.Y; x <= p1.X; x++ {
-		dst.Set(x, y, lookupGradient(col1, col2, p0, p1, x, y))
+		c := lookupGradient(col1, col2, p0, p1, x, y, space)
+		for i := -width / 2; i <= width/2; i++ {
+			blendPixel(dst, x, y+i, c)
+		}
 		if d > 0 {
 			y += yDir
 			d += -2 * dx
@@ -45,10 +95,11 @@ func drawLineHoriz(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color)
 }
 
 // drawLineVert along the Y axis.
-func drawLineVert(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color) {
-	// Start with the lowest point, swap if needed.
+func drawLineVert(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color, space ColorSpace, width int) {
+	// Start with the lowest point, swap if needed, along with the colors.
 	if p1.Y-p0.Y < 0 {
 		p0, p1 = p1, p0
+		col1, col2 = col2, col1
 	}
 
 	// Dims.
@@ -63,7 +114,10 @@ func drawLineVert(dst *image.RGBA, p0, p1 image.Point, col1, col2 color.Color) {
 
 	d := 2*dx - dy
 	for x, y := p0.X, p0.Y; y <= p1.Y; y++ {
-		dst.Set(x, y, lookupGradient(col1, col2, p0, p1, x, y))
+		c := lookupGradient(col1, col2, p0, p1, x, y, space)
+		for i := -width / 2; i <= width/2; i++ {
+			blendPixel(dst, x+i, y, c)
+		}
 		if d > 0 {
 			x += xDir
 			d += -2 * dy
@@ -85,6 +139,41 @@ func getLinePosition(start, end image.Point, curX, curY int) float64 {
 
 // lookupGradient gets the relative current position in the
 // line and returns the color gradient.
-func lookupGradient(col1, col2 color.Color, start, end image.Point, curX, curY int) color.Color {
-	return getGradientColor(col1, col2, getLinePosition(start, end, curX, curY))
+func lookupGradient(col1, col2 color.Color, start, end image.Point, curX, curY int, space ColorSpace) color.RGBA {
+	return getGradientColor(col1, col2, getLinePosition(start, end, curX, curY), space)
+}
+
+// scaleAlpha returns the premultiplied color c with its opacity scaled by the given coverage, between 0 and 1.
+func scaleAlpha(c color.RGBA, coverage float64) color.RGBA {
+	if coverage >= 1 {
+		return c
+	}
+	scale := func(v uint8) uint8 { return uint8(math.Round(float64(v) * coverage)) }
+	return color.RGBA{R: scale(c.R), G: scale(c.G), B: scale(c.B), A: scale(c.A)}
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// blendPixel composites the premultiplied color c over the pixel at x/y, i.e. Porter-Duff "over".
+// Opaque colors simply replace the pixel.
+func blendPixel(dst *image.RGBA, x, y int, c color.RGBA) {
+	if c.A == 0xFF {
+		dst.SetRGBA(x, y, c)
+		return
+	}
+	if c.A == 0 || !(image.Point{X: x, Y: y}.In(dst.Rect)) {
+		return
+	}
+	i := dst.PixOffset(x, y)
+	px := dst.Pix[i : i+4 : i+4]
+	inv := uint32(0xFF - c.A)
+	for j, v := range [4]uint8{c.R, c.G, c.B, c.A} {
+		px[j] = v + uint8((uint32(px[j])*inv+0x7F)/0xFF)
+	}
 }
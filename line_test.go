@@ -0,0 +1,136 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawLineBlend(t *testing.T) {
+	t.Parallel()
+
+	img := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF // Opaque white.
+	}
+	halfRed := color.RGBA{R: 0x80, A: 0x80}
+
+	// Horizontal then vertical translucent lines, crossing at 1/1.
+	drawLine(img, image.Pt(0, 1), image.Pt(2, 1), halfRed, halfRed, ColorSpaceSRGB, 1)
+	drawLine(img, image.Pt(1, 0), image.Pt(1, 2), halfRed, halfRed, ColorSpaceSRGB, 1)
+
+	for _, tc := range []struct {
+		x, y   int
+		expect color.RGBA
+	}{
+		{0, 0, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}}, // Untouched.
+		{0, 1, color.RGBA{0xFF, 0x7F, 0x7F, 0xFF}}, // Red over white, once.
+		{1, 1, color.RGBA{0xFF, 0x3F, 0x3F, 0xFF}}, // Twice, at the crossing.
+	} {
+		if got := img.RGBAAt(tc.x, tc.y); got != tc.expect {
+			t.Errorf("Unexpected pixel %d/%d.\nGot:      %v\nExpected: %v", tc.x, tc.y, got, tc.expect)
+		}
+	}
+
+	// Opaque colors replace the pixels, translucent ones are composited over transparent ones.
+	img = image.NewRGBA(image.Rect(0, 0, 2, 1))
+	drawLine(img, image.Pt(0, 0), image.Pt(1, 0), color.RGBA{B: 0xFF, A: 0xFF}, color.RGBA{B: 0x40, A: 0x40}, ColorSpaceSRGB, 1)
+	if got, expect := img.RGBAAt(0, 0), (color.RGBA{B: 0xFF, A: 0xFF}); got != expect {
+		t.Errorf("Unexpected opaque pixel.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if got, expect := img.RGBAAt(1, 0), (color.RGBA{B: 0x40, A: 0x40}); got != expect {
+		t.Errorf("Unexpected translucent pixel.\nGot:      %v\nExpected: %v", got, expect)
+	}
+}
+
+func TestDrawLineAA(t *testing.T) {
+	t.Parallel()
+
+	red, blue := color.RGBA{R: 0xFF, A: 0xFF}, color.RGBA{B: 0xFF, A: 0xFF}
+	halfRed := color.RGBA{R: 0x80, A: 0x80}
+
+	// Shallow then steep lines, the middle step straddling 2 pixels.
+	for _, tc := range []struct {
+		name   string
+		p0, p1 image.Point
+		expect map[image.Point]color.RGBA
+	}{
+		{"shallow", image.Pt(0, 0), image.Pt(2, 1), map[image.Point]color.RGBA{
+			{0, 0}: red, {1, 0}: halfRed, {1, 1}: halfRed, {2, 1}: red,
+		}},
+		{"steep", image.Pt(0, 0), image.Pt(1, 2), map[image.Point]color.RGBA{
+			{0, 0}: red, {0, 1}: halfRed, {1, 1}: halfRed, {1, 2}: red,
+		}},
+		{"straight", image.Pt(2, 0), image.Pt(0, 0), map[image.Point]color.RGBA{
+			{0, 0}: red, {1, 0}: red, {2, 0}: red,
+		}},
+	} {
+		img := image.NewRGBA(image.Rect(0, 0, 3, 3))
+		drawLineAA(img, tc.p0, tc.p1, red, red, ColorSpaceSRGB, 1)
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 3; x++ {
+				if got, expect := img.RGBAAt(x, y), tc.expect[image.Pt(x, y)]; got != expect {
+					t.Errorf("Unexpected %s pixel %d/%d.\nGot:      %v\nExpected: %v", tc.name, x, y, got, expect)
+				}
+			}
+		}
+	}
+
+	// The gradient follows the points, whichever way the line is drawn.
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	drawLineAA(img, image.Pt(2, 0), image.Pt(0, 0), red, blue, ColorSpaceSRGB, 1)
+	if got := img.RGBAAt(2, 0); got != red {
+		t.Errorf("Unexpected start pixel.\nGot:      %v\nExpected: %v", got, red)
+	}
+	if got := img.RGBAAt(0, 0); got != blue {
+		t.Errorf("Unexpected end pixel.\nGot:      %v\nExpected: %v", got, blue)
+	}
+}
+
+func TestDrawLineGradientDirection(t *testing.T) {
+	t.Parallel()
+
+	red, blue := color.RGBA{R: 0xFF, A: 0xFF}, color.RGBA{B: 0xFF, A: 0xFF}
+
+	// Both modes start from col1 at p0, whichever way the line goes.
+	for name, draw := range map[string]func(*image.RGBA, image.Point, image.Point, color.Color, color.Color, ColorSpace, int){
+		"aliased":      drawLine,
+		"anti-aliased": drawLineAA,
+	} {
+		for _, end := range []image.Point{{0, 2}, {2, 0}, {0, 0}} { // Horizontal, vertical, diagonal.
+			start := image.Pt(2, 2)
+			img := image.NewRGBA(image.Rect(0, 0, 3, 3))
+			draw(img, start, end, red, blue, ColorSpaceSRGB, 1)
+			if got := img.RGBAAt(start.X, start.Y); got != red {
+				t.Errorf("Unexpected %s start pixel toward %v.\nGot:      %v\nExpected: %v", name, end, got, red)
+			}
+			if got := img.RGBAAt(end.X, end.Y); got != blue {
+				t.Errorf("Unexpected %s end pixel toward %v.\nGot:      %v\nExpected: %v", name, end, got, blue)
+			}
+		}
+	}
+}
+
+func TestDrawLineWidth(t *testing.T) {
+	t.Parallel()
+
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	halfRed := color.RGBA{R: 0x80, A: 0x80}
+
+	// Horizontal line along y=2, 2 pixels thick, centered on it in both modes.
+	for name, tc := range map[string]struct {
+		draw   func(*image.RGBA, image.Point, image.Point, color.Color, color.Color, ColorSpace, int)
+		expect []color.RGBA // Column from y=0 to 4.
+	}{
+		"aliased":      {drawLine, []color.RGBA{{}, red, red, red, {}}}, // The half covered pixels are drawn.
+		"anti-aliased": {drawLineAA, []color.RGBA{{}, halfRed, red, halfRed, {}}},
+	} {
+		img := image.NewRGBA(image.Rect(0, 0, 3, 5))
+		tc.draw(img, image.Pt(0, 2), image.Pt(2, 2), red, red, ColorSpaceSRGB, 2)
+		for y, expect := range tc.expect {
+			if got := img.RGBAAt(1, y); got != expect {
+				t.Errorf("Unexpected %s pixel 1/%d.\nGot:      %v\nExpected: %v", name, y, got, expect)
+			}
+		}
+	}
+}
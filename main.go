@@ -3,44 +3,309 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	"go.creack.net/fdf/render"
 	"go.creack.net/fdf/render/ebitenrenderer"
 	"go.creack.net/fdf/render/pngrenderer"
 )
 
-//go:embed maps/*.fdf
+//go:embed maps
 var mapData embed.FS
 
 func main() {
+	// Sub commands, the default being to load and render a map.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "gen":
+			if err := runGen(os.Args[2:]); err != nil {
+				log.Fatalf("Gen: %s.", err)
+			}
+			return
+		case "transform":
+			if err := runTransform(os.Args[2:]); err != nil {
+				log.Fatalf("Transform: %s.", err)
+			}
+			return
+		case "calc":
+			if err := runCalc(os.Args[2:]); err != nil {
+				log.Fatalf("Calc: %s.", err)
+			}
+			return
+		case "diff":
+			if err := runDiff(os.Args[2:]); err != nil {
+				log.Fatalf("Diff: %s.", err)
+			}
+			return
+		case "info":
+			if err := runInfo(os.Args[2:]); err != nil {
+				log.Fatalf("Info: %s.", err)
+			}
+			return
+		}
+	}
+
 	var renderer, filePath, source string
 	flag.StringVar(&renderer, "r", "ebitengine", "Renderer: 'png' or 'ebitengine'. Always 'ebitengine' for WASM.")
 	flag.StringVar(&filePath, "f", "./fdf.png", "Only for 'png' renderer: path where to create the image.")
-	flag.StringVar(&source, "s", "maps/42.fdf", "Source .fdf map file.")
+	var transparent bool
+	flag.BoolVar(&transparent, "transparent", false, "Only for 'png' renderer: transparent background instead of the theme's.")
+	var antialias bool
+	flag.BoolVar(&antialias, "antialias", false, "Smooth the edges, slower. Toggled with 'x' in the 'ebitengine' window.")
+	var themeName string
+	flag.StringVar(&themeName, "theme", render.DefaultTheme().Name, "Style of the rendering: "+strings.Join(render.ThemeNames(), ", ")+".")
+	flag.StringVar(&source, "s", "maps/42.fdf", "Source map file. Path on disk, '-' for stdin or embedded map name.")
+	var watch time.Duration
+	flag.DurationVar(&watch, "watch", 0, "Only for 'ebitengine' renderer: check the map file for changes at the given interval, i.e. 500ms, and reload it.")
+	var format string
+	var listFormats bool
+	flag.StringVar(&format, "format", "", "Force the source map format instead of detecting it. See -formats.")
+	flag.BoolVar(&listFormats, "formats", false, "List the supported map formats and exit.")
+	var outPath string
+	var encOpts EncodeOptions
+	flag.StringVar(&outPath, "o", "", "Write the loaded map to the given path and exit. .fdf, or .obj/.ply/.stl to export the mesh. '-' for .fdf on stdout.")
+	flag.BoolVar(&encOpts.Align, "align", false, "Only with -o and .fdf: align the columns.")
+	var solid bool
+	solidOpts := SolidOptions{Base: 2}
+	flag.BoolVar(&solid, "solid", false, "Only with -o and .stl: export a watertight solid with side walls and a flat base, for 3D printing.")
+	flag.Float64Var(&solidOpts.Base, "base", solidOpts.Base, "Only with -solid: thickness of the base below the lowest point.")
+	flag.Float64Var(&solidOpts.Size, "size", solidOpts.Size, "Only with -solid: scale the largest side to the given size, in mm. 0 keeps the map units.")
+	heightFactor := 1.
+	flag.Float64Var(&heightFactor, "hf", heightFactor, "Height factor. Defaults to the map header's, if any.")
+	var palette string
+	var ignoreColors bool
+	flag.StringVar(&palette, "palette", "", "Color ramp of the points without explicit color, from the lowest to the highest: "+
+		strings.Join(RampNames(), ", ")+", or a color list, i.e. '0x0000FF,0xFF0000'. Defaults to the map header's, if any.")
+	flag.BoolVar(&ignoreColors, "ignore-colors", false, "Apply the palette to all the points, ignoring their explicit colors.")
+	var gradient string
+	flag.StringVar(&gradient, "gradient", "srgb", "Color space of the gradients along the edges: 'srgb', 'linear', 'hsl', 'hsv' or 'oklab'.")
+	var parseOpts ParseOptions
+	var ragged string
+	flag.BoolVar(&parseOpts.Lenient, "lenient", false, "Only for .fdf: tolerate tabs, CRLF and trailing junk.")
+	flag.StringVar(&ragged, "ragged", "allow", "Only for .fdf: rows of different lengths policy: 'allow', 'reject', 'pad' or 'truncate'.")
+	flag.Float64Var(&parseOpts.PadValue, "pad", 0, "Only for .fdf with -ragged pad: height of the padding points.")
+	var filters []Filter
+	flag.Func("blur", "Filter: gaussian blur with the given radius, in points. Filters are applied in the order of the flags.", func(value string) error {
+		radius, err := strconv.ParseFloat(value, 64)
+		if err != nil || !(radius > 0) {
+			return fmt.Errorf("invalid radius %q", value)
+		}
+		filters = append(filters, GaussianBlur(radius))
+		return nil
+	})
+	flag.Func("median", "Filter: median of the neighbors within the given radius, in points.", func(value string) error {
+		radius, err := strconv.Atoi(value)
+		if err != nil || radius < 1 {
+			return fmt.Errorf("invalid radius %q", value)
+		}
+		filters = append(filters, Median(radius))
+		return nil
+	})
+	flag.Func("terrace", "Filter: quantize the heights to the given number of levels.", func(value string) error {
+		levels, err := strconv.Atoi(value)
+		if err != nil || levels < 2 {
+			return fmt.Errorf("invalid levels %q", value)
+		}
+		filters = append(filters, Terrace(levels))
+		return nil
+	})
+	flag.Func("clamp", "Filter: clamp the heights to 'low,high'.", func(value string) error {
+		lo, hi, err := parseRange(value)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, Clamp(lo, hi))
+		return nil
+	})
+	flag.Func("normalize", "Filter: normalize the heights to 'low,high'.", func(value string) error {
+		lo, hi, err := parseRange(value)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, Normalize(lo, hi))
+		return nil
+	})
+	hmOpts := DefaultHeightmapOptions()
+	flag.Float64Var(&hmOpts.MinHeight, "hmin", hmOpts.MinHeight, "Only for heightmap images: height of the darkest pixels.")
+	flag.Float64Var(&hmOpts.MaxHeight, "hmax", hmOpts.MaxHeight, "Only for heightmap images: height of the brightest pixels.")
+	flag.IntVar(&hmOpts.Step, "hstep", hmOpts.Step, "Only for heightmap images: downsample by averaging blocks of NxN pixels.")
+	flag.IntVar(&hmOpts.MaxSize, "hsize", hmOpts.MaxSize, "Only for heightmap images: max map width/height, downsamples as needed. 0 to disable.")
+	flag.BoolVar(&hmOpts.Color, "hcolor", hmOpts.Color, "Only for heightmap images: use the pixel colors.")
 	flag.Parse()
 
-	if runtime.GOOS == "js" {
-		renderer = "ebitengine"
+	if listFormats {
+		for _, f := range Formats() {
+			fmt.Printf("%-10s %s\n", f.Name, strings.Join(f.Exts, " "))
+		}
+		return
+	}
+
+	policy, err := ParseRaggedPolicy(ragged)
+	if err != nil {
+		log.Fatalf("Invalid -ragged: %s.", err)
+	}
+	parseOpts.Ragged = policy
+	colorSpace, err := ParseColorSpace(gradient)
+	if err != nil {
+		log.Fatalf("Invalid -gradient: %s.", err)
+	}
+	theme, err := render.LookupTheme(themeName)
+	if err != nil {
+		log.Fatalf("Invalid -theme: %s.", err)
 	}
 
-	g, err := NewFdf(mapData, source)
+	g, err := loadSource(source, LoadOptions{Progress: logProgress, Format: format, Parse: parseOpts, Heightmap: hmOpts})
 	if err != nil {
-		log.Fatalf("NewFdf: %s.", err)
+		// Report all the parse errors, not only the first one.
+		var perrs ParseErrors
+		if errors.As(err, &perrs) {
+			for _, elem := range perrs {
+				fmt.Fprintf(os.Stderr, "%s: %s.\n", source, elem)
+			}
+		}
+		log.Fatalf("Load source: %s.", err)
+	}
+
+	g.SetFilters(filters...)
+
+	// Only override the map's header when explicitly set.
+	var paletteErr error
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "hf":
+			g.SetDefaultHeightFactor(heightFactor)
+		case "palette":
+			paletteErr = g.SetPalette(palette)
+		}
+	})
+	if paletteErr != nil {
+		log.Fatalf("Invalid -palette: %s.", paletteErr)
 	}
+	g.SetIgnoreColors(ignoreColors)
+	g.SetColorSpace(colorSpace)
+	g.SetTransparentBackground(transparent)
+	g.SetTheme(theme)
+	g.SetAntialias(antialias)
+
+	if outPath != "" {
+		if solid {
+			err = writeSolid(outPath, g, solidOpts)
+		} else {
+			err = writeMap(outPath, g, encOpts)
+		}
+		if err != nil {
+			log.Fatalf("Write map: %s.", err)
+		}
+		return
+	}
+
+	if err := runRenderer(renderer, filePath, g, watch); err != nil {
+		log.Fatal(err)
+	}
+}
 
+// runRenderer renders the engine with the given renderer, 'png' or 'ebitengine'.
+// watch is the interval at which ebitengine reloads the map file if changed, 0 to disable.
+func runRenderer(renderer, filePath string, g *Fdf, watch time.Duration) error {
+	if runtime.GOOS == "js" {
+		renderer = "ebitengine"
+	}
 	switch renderer {
 	case "png":
-		if err := pngrenderer.New(filePath, 2050, 1100).Run(g); err != nil {
-			log.Fatal(err)
-		}
+		return pngrenderer.New(filePath, 2050, 1100).Run(g)
 	case "ebitengine":
 		println("Starting ebitengine.")
-		if err := ebitenrenderer.New(300, 300).Run(g); err != nil {
-			log.Fatal(err)
-		}
+		return ebitenrenderer.New(300, 300, ebitenrenderer.Options{Watch: watch}).Run(g)
 	default:
-		log.Fatalf("Invalid renderer %q.", renderer)
+		return fmt.Errorf("invalid renderer %q", renderer)
+	}
+}
+
+// loadSource creates the engine from the given source.
+//
+// "-" reads the map from stdin, an existing file on disk is loaded
+// from its directory so the other maps there can be cycled through,
+// otherwise, falls back to the embedded maps.
+func loadSource(source string, opts LoadOptions) (*Fdf, error) {
+	if source == "-" {
+		return NewFdfFromReader(os.Stdin, "stdin", opts)
+	}
+
+	var mapFS fs.FS = mapData
+	if st, err := os.Stat(source); err == nil && st.Mode().IsRegular() {
+		dir, name := filepath.Split(source)
+		if dir == "" {
+			dir = "."
+		}
+		mapFS, source = os.DirFS(dir), name
+	}
+	return NewFdf(mapFS, source, opts)
+}
+
+// writeMap writes the map to the given path, '-' for stdout.
+// The output format is picked from the extension: .obj, .ply and .stl export
+// the mesh, anything else is written as .fdf.
+func writeMap(outPath string, g *Fdf, opts EncodeOptions) error {
+	encode := func(w io.Writer) error { return encodeMap(w, g.Map(), opts) }
+	switch strings.ToLower(filepath.Ext(outPath)) {
+	case ".obj":
+		encode = func(w io.Writer) error { return writeOBJ(w, g.Mesh()) }
+	case ".ply":
+		encode = func(w io.Writer) error { return writePLY(w, g.Mesh()) }
+	case ".stl":
+		encode = func(w io.Writer) error { return writeSTL(w, g.Mesh()) }
+	}
+
+	return writeOutput(outPath, encode)
+}
+
+// writeSolid exports the solid mesh of the map as STL to the given path.
+func writeSolid(outPath string, g *Fdf, opts SolidOptions) error {
+	if ext := strings.ToLower(filepath.Ext(outPath)); ext != ".stl" {
+		return fmt.Errorf("solid export only supports .stl, got %q", ext)
+	}
+	mesh, err := g.SolidMesh(opts)
+	if err != nil {
+		return fmt.Errorf("solidMesh: %w", err)
+	}
+	return writeOutput(outPath, func(w io.Writer) error { return writeSTL(w, mesh) })
+}
+
+// writeOutput creates the file at the given path, '-' for stdout, and writes to it using encode.
+func writeOutput(outPath string, encode func(io.Writer) error) error {
+	if outPath == "-" {
+		return encode(os.Stdout)
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	if err := encode(f); err != nil {
+		_ = f.Close() // Best effort.
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	return nil
+}
+
+// logProgress reports the map loading progress on stderr.
+func logProgress(read, total int64) {
+	if total <= 0 {
+		fmt.Fprintf(os.Stderr, "Loading: %d MiB.\n", read>>20)
+		return
 	}
+	fmt.Fprintf(os.Stderr, "Loading: %d MiB (%d%%).\n", read>>20, read*100/total)
 }
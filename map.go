@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"image/color"
+	"io"
+	"math"
 	"strconv"
 	"strings"
 
@@ -10,72 +15,362 @@ import (
 )
 
 //nolint:gochecknoglobals // Expected "readonly" global.
-var defaultColor = color.White
-
-func parseMap(mapData []byte) ([][]MapPoint, error) {
-	// Start by cleaning up the input, removing blank lines and dup spaces.
-	//nolint:prealloc // False positive.
-	var grid [][]string
-	for _, line := range strings.Split(string(mapData), "\n") {
-		if line == "" {
+var defaultColor = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+
+// Map is a decoded map with its metadata.
+type Map struct {
+	Points [][]MapPoint
+
+	// CellX and CellY are the distances between two points along X and Y,
+	// in height units.
+	CellX, CellY float64
+
+	// Meta holds the optional viewing settings.
+	Meta MapMeta
+}
+
+// makeGrid allocates a width x height map backed by a single flat slice,
+// with the X/Y coordinates and the default color set.
+func makeGrid(width, height int) [][]MapPoint {
+	points := make([]MapPoint, width*height)
+	m := make([][]MapPoint, height)
+	for y := range m {
+		m[y] = points[y*width : (y+1)*width : (y+1)*width]
+		for x := range m[y] {
+			m[y][x] = MapPoint{Vec: math3.Vec{X: float64(x), Y: float64(y)}, color: defaultColor}
+		}
+	}
+	return m
+}
+
+// RaggedPolicy defines how rows of different lengths are handled.
+type RaggedPolicy byte
+
+// RaggedPolicy enum values.
+const (
+	RaggedAllow    RaggedPolicy = iota // Keep the rows as-is.
+	RaggedReject                       // Report an error for each row not matching the first one.
+	RaggedPad                          // Pad the short rows up to the longest one.
+	RaggedTruncate                     // Truncate the rows to the shortest one.
+)
+
+// ParseRaggedPolicy parses the policy name: allow, reject, pad or truncate.
+func ParseRaggedPolicy(name string) (RaggedPolicy, error) {
+	switch name {
+	case "allow", "":
+		return RaggedAllow, nil
+	case "reject":
+		return RaggedReject, nil
+	case "pad":
+		return RaggedPad, nil
+	case "truncate":
+		return RaggedTruncate, nil
+	default:
+		return 0, fmt.Errorf("unknown ragged row policy %q", name)
+	}
+}
+
+// ParseOptions controls the .fdf parser.
+type ParseOptions struct {
+	// Ragged defines how rows of different lengths are handled.
+	Ragged RaggedPolicy
+	// PadValue is the height used to pad the short rows with RaggedPad.
+	PadValue float64
+
+	// Lenient tolerates tabs and CRLF line endings, and ignores trailing junk
+	// at the end of the lines.
+	Lenient bool
+
+	// Warn, when set, is called for the suspicious but valid elements,
+	// e.g. the junk ignored in lenient mode or the comments looking like invalid directives.
+	Warn func(*ParseError)
+}
+
+// noDataToken marks a missing point, i.e. a hole. 'nan' is accepted as well.
+const noDataToken = "_"
+
+// maxParseErrors is the number of errors after which the parser gives up.
+const maxParseErrors = 100
+
+// ParseError is an error at a given position of a .fdf map.
+type ParseError struct {
+	Line, Column int // 1-based, in the file. Column is in bytes.
+	Token        string
+	Err          error
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d: %q: %s", e.Line, e.Column, e.Token, e.Err)
+}
+
+// Unwrap returns the cause.
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// ParseErrors lists all the errors found while parsing a map.
+type ParseErrors []*ParseError
+
+// Error implements the error interface.
+func (e ParseErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0], len(e)-1)
+}
+
+// Unwrap returns the individual errors.
+func (e ParseErrors) Unwrap() []error {
+	out := make([]error, 0, len(e))
+	for _, elem := range e {
+		out = append(out, elem)
+	}
+	return out
+}
+
+// parseMap reads the .fdf map points from r, see readMap.
+func parseMap(r io.Reader, opts ParseOptions) ([][]MapPoint, error) {
+	m, err := readMap(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return m.Points, nil
+}
+
+// readMap reads the .fdf map from r, line by line, along with its header, see MapMeta.
+// Lines starting with '#' which are not header directives are ignored as comments.
+//
+// The points are stored in a single flat slice, each row being a sub-slice of it,
+// so the memory footprint stays close to the number of points regardless of the
+// number of rows.
+//
+// All the errors are collected, up to maxParseErrors, and returned as ParseErrors.
+func readMap(r io.Reader, opts ParseOptions) (*Map, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	out := &Map{CellX: 1, CellY: 1}
+	var (
+		points   []MapPoint
+		rowEnds  []int
+		rowLines []int // File line of each row, for the ragged row errors.
+		errs     ParseErrors
+		line     []byte
+		err      error
+	)
+	for lineNum := 1; err == nil && len(errs) < maxParseErrors; lineNum++ {
+		line, err = readLine(br, line[:0])
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("read line %d: %w", lineNum, err)
+		}
+		if opts.Lenient {
+			if lineNum == 1 {
+				line = bytes.TrimPrefix(line, []byte("\xEF\xBB\xBF")) // UTF-8 BOM.
+			}
+			line = bytes.TrimSuffix(line, []byte{'\r'})
+		}
+		// Skip blank lines.
+		if len(line) == 0 {
 			continue
 		}
-		var gridLine []string
-		for _, elem := range strings.Split(line, " ") {
-			if elem == "" {
-				continue
+		if isComment(line) {
+			// Directives are only valid before the first row.
+			if len(rowEnds) == 0 {
+				// Invalid directives are kept as comments, i.e. "# cell: see below".
+				if err := parseDirective(out, string(line[1:])); err != nil && !errors.Is(err, errUnknownDirective) && opts.Warn != nil {
+					opts.Warn(&ParseError{Line: lineNum, Err: fmt.Errorf("not a valid directive, ignored: %w", err)})
+				}
 			}
-			gridLine = append(gridLine, elem)
+			continue
 		}
-		grid = append(grid, gridLine)
+
+		points, errs = parseLine(points, errs, line, lineNum, len(rowEnds), opts)
+		rowEnds = append(rowEnds, len(points))
+		rowLines = append(rowLines, lineNum)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if len(rowEnds) == 0 {
+		return nil, fmt.Errorf("no points")
 	}
 
-	// Then for each point, parse the height and optional color.
-	//nolint:prealloc // False positive.
-	var m [][]MapPoint
-	for y, line := range grid {
-		var points []MapPoint
-		for x, elem := range line {
-			parts := strings.Split(elem, ",")
+	// Slice the flat points into rows. Capping the capacity
+	// so appending to a row can't overwrite the next one.
+	m := make([][]MapPoint, 0, len(rowEnds))
+	start := 0
+	for _, end := range rowEnds {
+		m = append(m, points[start:end:end])
+		start = end
+	}
 
-			h, err := strconv.Atoi(parts[0])
-			if err != nil {
-				return nil, fmt.Errorf("invalid height %q for %d/%d: %w", parts[0], y, x, err)
-			}
+	if out.Points, err = applyRaggedPolicy(m, rowLines, opts); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
-			p := MapPoint{
-				Vec: math3.Vec{
-					X: float64(x),
-					Y: float64(y),
-					Z: float64(h),
-				},
-				color: defaultColor,
-			}
+// parseLine parses the points of the given line, appending them to points and the errors to errs.
+//
+// In lenient mode, the invalid elements at the end of the line are ignored if the line has valid points,
+// reported through opts.Warn. The errors are capped to maxParseErrors, a single line can't collect more.
+func parseLine(points []MapPoint, errs ParseErrors, line []byte, lineNum, y int, opts ParseOptions) ([]MapPoint, ParseErrors) {
+	isSep := func(c byte) bool { return c == ' ' || (opts.Lenient && (c == '\t' || c == '\r')) }
 
-			if len(parts) > 1 {
-				col, err := rgbaFromHexString(parts[1])
-				if err != nil {
-					return nil, fmt.Errorf("invalid color %q for %d/%d: %w", parts[1], y, x, err)
-				}
-				col.A = 255
-				p.color = col
+	var pending ParseErrors // Errors that may turn out to be trailing junk.
+	start, x := len(points), 0
+	for col := 0; col < len(line); {
+		if isSep(line[col]) {
+			col++
+			continue
+		}
+		end := col
+		for end < len(line) && !isSep(line[end]) {
+			end++
+		}
+		p, perr := parsePoint(line[col:end], x, y)
+		if perr != nil {
+			if len(errs)+len(pending) < maxParseErrors {
+				perr.Line, perr.Column = lineNum, col+perr.Column+1
+				pending = append(pending, perr)
 			}
-
+		} else {
+			errs, pending = append(errs, pending...), pending[:0]
 			points = append(points, p)
 		}
-		m = append(m, points)
+		x++
+		col = end
 	}
+	// Only junk after valid points can be ignored.
+	if !opts.Lenient || len(points) == start {
+		return points, append(errs, pending...)
+	}
+	if opts.Warn != nil {
+		for _, elem := range pending {
+			elem.Err = fmt.Errorf("trailing junk ignored: %w", elem.Err)
+			opts.Warn(elem)
+		}
+	}
+	return points, errs
+}
 
-	if len(m) == 0 {
-		return nil, fmt.Errorf("no points")
+// applyRaggedPolicy checks/fixes the row lengths according to the policy.
+func applyRaggedPolicy(m [][]MapPoint, rowLines []int, opts ParseOptions) ([][]MapPoint, error) {
+	minWidth, maxWidth := len(m[0]), len(m[0])
+	for _, line := range m {
+		minWidth, maxWidth = min(minWidth, len(line)), max(maxWidth, len(line))
+	}
+	if minWidth == maxWidth {
+		return m, nil
 	}
 
+	switch opts.Ragged {
+	case RaggedAllow:
+	case RaggedReject:
+		var errs ParseErrors
+		for y, line := range m {
+			if len(line) != len(m[0]) && len(errs) < maxParseErrors {
+				errs = append(errs, &ParseError{
+					Line: rowLines[y],
+					Err:  fmt.Errorf("ragged row: %d points, expected %d", len(line), len(m[0])),
+				})
+			}
+		}
+		return nil, errs
+	case RaggedPad:
+		padded := makeGrid(maxWidth, len(m))
+		for y, line := range padded {
+			copy(line, m[y])
+			for x := len(m[y]); x < maxWidth; x++ {
+				line[x].Z = opts.PadValue
+			}
+		}
+		return padded, nil
+	case RaggedTruncate:
+		for y, line := range m {
+			m[y] = line[:minWidth:minWidth]
+		}
+	}
 	return m, nil
 }
 
+// parsePoint parses a single "height[,color]" element.
+// The returned error's column is relative to the element.
+func parsePoint(elem []byte, x, y int) (MapPoint, *ParseError) {
+	heightStr, colorStr, hasColor := bytes.Cut(elem, []byte{','})
+
+	h, err := parseHeight(string(heightStr))
+	if err != nil {
+		return MapPoint{}, &ParseError{Token: string(elem), Err: fmt.Errorf("invalid height %q: %w", heightStr, err)}
+	}
+
+	p := MapPoint{
+		Vec: math3.Vec{
+			X: float64(x),
+			Y: float64(y),
+			Z: h,
+		},
+		color: defaultColor,
+	}
+
+	if hasColor {
+		// Ignore anything after an extra comma.
+		colorStr, _, _ = bytes.Cut(colorStr, []byte{','})
+		col, err := rgbaFromHexString(string(colorStr))
+		if err != nil {
+			return MapPoint{}, &ParseError{Column: len(heightStr) + 1, Token: string(elem), Err: fmt.Errorf("invalid color %q: %w", colorStr, err)}
+		}
+		p.color, p.explicit = col, true
+	}
+
+	return p, nil
+}
+
+// parseHeight parses the given height. Integers are the common case,
+// floats and scientific notation are supported as well.
+//
+// The NODATA tokens, '_' and 'nan', return NaN, i.e. a hole.
+func parseHeight(str string) (float64, error) {
+	if h, err := strconv.Atoi(str); err == nil {
+		return float64(h), nil
+	}
+	if str == noDataToken || strings.EqualFold(str, "nan") {
+		return math.NaN(), nil
+	}
+	h, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse float: %w", err)
+	}
+	if math.IsNaN(h) || math.IsInf(h, 0) {
+		return 0, fmt.Errorf("non-finite value")
+	}
+	return h, nil
+}
+
+// readLine reads a full line from br into buf, without the trailing newline.
+// Lines longer than the reader's buffer are accumulated in buf.
+// Returns io.EOF with the last line if any.
+func readLine(br *bufio.Reader, buf []byte) ([]byte, error) {
+	for {
+		chunk, err := br.ReadSlice('\n')
+		buf = append(buf, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return bytes.TrimSuffix(buf, []byte{'\n'}), err
+	}
+}
+
 // MapPoint represents an individual point for the wireframe.
 // 3d vector with color.
 type MapPoint struct {
 	math3.Vec
-	color color.Color
+	color    color.RGBA
+	explicit bool // Whether the color is set by the map, i.e. not the default one.
 }
+
+// IsHole returns true if the point is missing from the map, i.e. NODATA.
+// Holes are stored with a NaN height.
+func (p MapPoint) IsHole() bool { return math.IsNaN(p.Z) }
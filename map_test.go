@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"maps"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.creack.net/fdf/projection"
+)
+
+// legacyParseMap is the original string based parser, kept as reference.
+func legacyParseMap(mapData []byte) ([][]MapPoint, error) {
+	var m [][]MapPoint
+	y := 0
+	for _, line := range strings.Split(string(mapData), "\n") {
+		if line == "" {
+			continue
+		}
+		points := []MapPoint{}
+		x := 0
+		for _, elem := range strings.Split(line, " ") {
+			if elem == "" {
+				continue
+			}
+			parts := strings.Split(elem, ",")
+			h, err := strconv.Atoi(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid height %q: %w", parts[0], err)
+			}
+			p := MapPoint{color: defaultColor}
+			p.X, p.Y, p.Z = float64(x), float64(y), float64(h)
+			if len(parts) > 1 {
+				col, err := legacyRGBFromHexString(parts[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid color %q: %w", parts[1], err)
+				}
+				p.color, p.explicit = col, true
+			}
+			points = append(points, p)
+			x++
+		}
+		m = append(m, points)
+		y++
+	}
+	return m, nil
+}
+
+// legacyRGBFromHexString is the original color parser, kept as reference.
+// The value alone tells 0xRRGGBBAA from 0xRRGGBB and the alpha is always dropped.
+func legacyRGBFromHexString(str string) (color.RGBA, error) {
+	c, err := strconv.ParseUint(strings.TrimPrefix(str, "0x"), 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("parse uint: %w", err)
+	}
+	if c > 0xFFFFFF {
+		c >>= 8
+	}
+	return color.RGBA{R: uint8(c >> 16), G: uint8(c >> 8), B: uint8(c), A: 0xFF}, nil
+}
+
+func assertSameGrid(t *testing.T, got, expect [][]MapPoint) {
+	t.Helper()
+
+	if len(got) != len(expect) {
+		t.Fatalf("Unexpected row count.\nGot:      %d\nExpected: %d", len(got), len(expect))
+	}
+	for y := range expect {
+		if len(got[y]) != len(expect[y]) {
+			t.Fatalf("Unexpected column count for row %d.\nGot:      %d\nExpected: %d", y, len(got[y]), len(expect[y]))
+		}
+		for x := range expect[y] {
+			if got[y][x] != expect[y][x] {
+				t.Fatalf("Unexpected point %d/%d.\nGot:      %v\nExpected: %v", y, x, got[y][x], expect[y][x])
+			}
+		}
+	}
+}
+
+func TestParseMapEmbedded(t *testing.T) {
+	t.Parallel()
+
+	entries, err := fs.ReadDir(mapData, "maps")
+	if err != nil {
+		t.Fatalf("ReadDir: %s.", err)
+	}
+	for _, elem := range entries {
+		elem := elem
+		t.Run(elem.Name(), func(t *testing.T) {
+			t.Parallel()
+
+			buf, err := fs.ReadFile(mapData, "maps/"+elem.Name())
+			if err != nil {
+				t.Fatalf("ReadFile: %s.", err)
+			}
+			expect, err := legacyParseMap(buf)
+			if err != nil {
+				t.Fatalf("legacyParseMap: %s.", err)
+			}
+			got, err := parseMap(bytes.NewReader(buf), ParseOptions{})
+			if err != nil {
+				t.Fatalf("parseMap: %s.", err)
+			}
+			assertSameGrid(t, got, expect)
+		})
+	}
+}
+
+func TestParseMapLongLine(t *testing.T) {
+	t.Parallel()
+
+	// Lines longer than the reader buffer must be read in full.
+	const width = 100000
+	line := strings.Repeat("1,0xFF0000 ", width)
+	got, err := parseMap(strings.NewReader(line+"\n"+line), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	if len(got) != 2 || len(got[0]) != width || len(got[1]) != width {
+		t.Fatalf("Unexpected grid size: %d rows.", len(got))
+	}
+	if expect := (color.RGBA{R: 0xFF, A: 0xFF}); got[1][width-1].color != expect {
+		t.Fatalf("Unexpected last point color.\nGot:      %v\nExpected: %v", got[1][width-1].color, expect)
+	}
+}
+
+func TestParseMapFloatHeights(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseMap(strings.NewReader("0 12.5 -0.25,0xFF0000\n1e3 -2.5E-1 7\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	expect := [][]float64{{0, 12.5, -0.25}, {1000, -0.25, 7}}
+	for y, line := range expect {
+		for x, h := range line {
+			if got[y][x].Z != h {
+				t.Errorf("Unexpected height for %d/%d.\nGot:      %v\nExpected: %v", y, x, got[y][x].Z, h)
+			}
+		}
+	}
+
+	for _, elem := range []string{"inf", "-Inf", "1.2.3", "1e", "__"} {
+		if _, err := parseMap(strings.NewReader("0 "+elem), ParseOptions{}); err == nil {
+			t.Errorf("Expected error for height %q.", elem)
+		}
+	}
+}
+
+func TestEncodeMapRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	entries, err := fs.ReadDir(mapData, "maps")
+	if err != nil {
+		t.Fatalf("ReadDir: %s.", err)
+	}
+	for _, elem := range entries {
+		elem := elem
+		t.Run(elem.Name(), func(t *testing.T) {
+			t.Parallel()
+
+			f, err := mapData.Open("maps/" + elem.Name())
+			if err != nil {
+				t.Fatalf("Open: %s.", err)
+			}
+			defer func() { _ = f.Close() }() // Best effort.
+			expect, err := parseMap(f, ParseOptions{})
+			if err != nil {
+				t.Fatalf("parseMap: %s.", err)
+			}
+
+			for _, opts := range []EncodeOptions{{}, {Align: true}} {
+				buf := bytes.NewBuffer(nil)
+				if err := encodeMap(buf, &Map{Points: expect}, opts); err != nil {
+					t.Fatalf("encodeMap: %s.", err)
+				}
+				got, err := parseMap(buf, ParseOptions{})
+				if err != nil {
+					t.Fatalf("parseMap encoded: %s.", err)
+				}
+				assertSameGrid(t, got, expect)
+			}
+		})
+	}
+}
+
+func TestEncodeMapEmptyRow(t *testing.T) {
+	t.Parallel()
+
+	// A blank line is skipped on parse, the empty row would be lost.
+	m := [][]MapPoint{{{}, {}}, {}, {{}, {}}}
+	if err := encodeMap(bytes.NewBuffer(nil), &Map{Points: m}, EncodeOptions{}); err == nil {
+		t.Fatal("Expected error for empty row.")
+	}
+}
+
+func TestParseMapErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseMap(strings.NewReader("0 1 2\n0 x 2\n\n0 1 2,0xZZ\n"), ParseOptions{})
+	var perrs ParseErrors
+	if !errors.As(err, &perrs) {
+		t.Fatalf("Unexpected error type %T: %v.", err, err)
+	}
+	if len(perrs) != 2 {
+		t.Fatalf("Unexpected error count.\nGot:      %d\nExpected: %d", len(perrs), 2)
+	}
+	for i, expect := range []ParseError{{Line: 2, Column: 3, Token: "x"}, {Line: 4, Column: 7, Token: "2,0xZZ"}} {
+		if got := perrs[i]; got.Line != expect.Line || got.Column != expect.Column || got.Token != expect.Token {
+			t.Errorf("Unexpected error %d.\nGot:      %d:%d %q\nExpected: %d:%d %q", i, got.Line, got.Column, got.Token, expect.Line, expect.Column, expect.Token)
+		}
+	}
+
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != 2 {
+		t.Fatalf("Unexpected first error: %v.", perr)
+	}
+}
+
+func TestParseMapRagged(t *testing.T) {
+	t.Parallel()
+
+	const input = "1 2 3\n4 5\n6 7 8 9\n"
+	for _, tc := range []struct {
+		policy RaggedPolicy
+		widths []int
+	}{
+		{RaggedAllow, []int{3, 2, 4}},
+		{RaggedPad, []int{4, 4, 4}},
+		{RaggedTruncate, []int{2, 2, 2}},
+	} {
+		m, err := parseMap(strings.NewReader(input), ParseOptions{Ragged: tc.policy, PadValue: -1})
+		if err != nil {
+			t.Fatalf("parseMap %d: %s.", tc.policy, err)
+		}
+		for y, line := range m {
+			if len(line) != tc.widths[y] {
+				t.Fatalf("Unexpected row %d width with policy %d.\nGot:      %d\nExpected: %d", y, tc.policy, len(line), tc.widths[y])
+			}
+		}
+		if tc.policy == RaggedPad {
+			if p := m[1][3]; p.Z != -1 || p.X != 3 || p.Y != 1 {
+				t.Fatalf("Unexpected padding point %v.", p)
+			}
+		}
+	}
+
+	_, err := parseMap(strings.NewReader(input), ParseOptions{Ragged: RaggedReject})
+	var perrs ParseErrors
+	if !errors.As(err, &perrs) || len(perrs) != 2 || perrs[0].Line != 2 || perrs[1].Line != 3 {
+		t.Fatalf("Unexpected ragged reject error: %v.", err)
+	}
+}
+
+func TestParseMapLenient(t *testing.T) {
+	t.Parallel()
+
+	const input = "\uFEFF1\t2 3\r\n4 5 6 ;junk\r\n"
+	if _, err := parseMap(strings.NewReader(input), ParseOptions{}); err == nil {
+		t.Fatal("Expected error in strict mode.")
+	}
+
+	var warnings []*ParseError
+	got, err := parseMap(strings.NewReader(input), ParseOptions{Lenient: true, Warn: func(err *ParseError) { warnings = append(warnings, err) }})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	expect, err := parseMap(strings.NewReader("1 2 3\n4 5 6\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	assertSameGrid(t, got, expect)
+
+	// The ignored junk is reported.
+	if len(warnings) != 1 || warnings[0].Line != 2 || warnings[0].Column != 7 {
+		t.Errorf("Unexpected warnings.\nGot:      %v\nExpected: the junk at line 2, column 7", warnings)
+	}
+}
+
+func TestParseMapErrorsCap(t *testing.T) {
+	t.Parallel()
+
+	// A single line can't collect more than maxParseErrors errors.
+	_, err := parseMap(strings.NewReader(strings.Repeat("x ", 10*maxParseErrors)+"\n"), ParseOptions{})
+	var perrs ParseErrors
+	if !errors.As(err, &perrs) {
+		t.Fatalf("Unexpected error type %T: %v.", err, err)
+	}
+	if len(perrs) != maxParseErrors {
+		t.Errorf("Unexpected error count.\nGot:      %d\nExpected: %d", len(perrs), maxParseErrors)
+	}
+}
+
+func TestParseMapHoles(t *testing.T) {
+	t.Parallel()
+
+	m, err := parseMap(strings.NewReader("1 _ 3\nnan 2 NaN\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	for _, p := range [][2]int{{0, 1}, {1, 0}, {1, 2}} {
+		if !m[p[0]][p[1]].IsHole() {
+			t.Fatalf("Point %d/%d should be a hole.", p[0], p[1])
+		}
+	}
+	if m[1][1].IsHole() {
+		t.Fatal("Point 1/1 should not be a hole.")
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := encodeMap(buf, &Map{Points: m}, EncodeOptions{}); err != nil {
+		t.Fatalf("encodeMap: %s.", err)
+	}
+	if got, expect := buf.String(), "1 _ 3\n_ 2 _\n"; got != expect {
+		t.Fatalf("Unexpected encoded map.\nGot:      %q\nExpected: %q", got, expect)
+	}
+
+	// Holes are ignored by the bounds.
+	m, err = parseMap(strings.NewReader("0 0 _\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	g := &Fdf{Points: m, projection: projection.NewDirect(), heightFactor: 1, cellX: 1, cellY: 1}
+	if bounds := g.getProjectedBounds(); bounds.Max.X != 1 {
+		t.Fatalf("Unexpected bounds %v.", bounds)
+	}
+	_ = g.Draw()
+}
+
+func TestParseMap42Colors(t *testing.T) {
+	t.Parallel()
+
+	// Counts from the original parser, so 42.fdf keeps its look.
+	buf, err := fs.ReadFile(mapData, "maps/42.fdf")
+	if err != nil {
+		t.Fatalf("ReadFile: %s.", err)
+	}
+	m, err := parseMap(bytes.NewReader(buf), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	got := map[color.RGBA]int{}
+	for _, line := range m {
+		for _, elem := range line {
+			got[elem.color]++
+		}
+	}
+	magenta, orange, cyan := color.RGBA{R: 0xFF, B: 0xFF, A: 0xFF}, color.RGBA{R: 0xFF, G: 0x11, A: 0xFF}, color.RGBA{G: 0xFF, B: 0xFF, A: 0xFF}
+	expect := map[color.RGBA]int{magenta: 158, orange: 50, cyan: 1}
+	if !maps.Equal(got, expect) {
+		t.Errorf("Unexpected 42.fdf colors.\nGot:      %v\nExpected: %v", got, expect)
+	}
+	if c := m[0][1].color; c != cyan {
+		t.Errorf("Unexpected 42.fdf color at 1/0.\nGot:      %v\nExpected: %v", c, cyan)
+	}
+}
+
+func TestParseMapAlpha(t *testing.T) {
+	t.Parallel()
+
+	// The digit count tells RGBA from RGB, even with a 0 red channel.
+	m, err := parseMap(strings.NewReader("1,0xFF000080 2,0x00FF00 3,0xFFFFFFFF 4,0x0000FF80\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	// Premultiplied, as expected from color.RGBA.
+	for x, expect := range []color.RGBA{{R: 0x80, A: 0x80}, {G: 0xFF, A: 0xFF}, {R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}, {B: 0x80, A: 0x80}} {
+		if got := m[0][x].color; got != expect {
+			t.Errorf("Unexpected color for point %d.\nGot:      %v\nExpected: %v", x, got, expect)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := encodeMap(buf, &Map{Points: m}, EncodeOptions{}); err != nil {
+		t.Fatalf("encodeMap: %s.", err)
+	}
+	if got, expect := buf.String(), "1,0xFF000080 2,0x00FF00 3,0xFFFFFF 4,0x0000FF80\n"; got != expect {
+		t.Fatalf("Unexpected encoded map.\nGot:      %q\nExpected: %q", got, expect)
+	}
+	got, err := parseMap(buf, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap encoded: %s.", err)
+	}
+	assertSameGrid(t, got, m)
+
+	if _, err := parseMap(strings.NewReader("1,0x1FF0000\n"), ParseOptions{}); err == nil {
+		t.Fatal("Expected error for out of range color.")
+	}
+}
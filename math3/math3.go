@@ -37,6 +37,39 @@ func (v Vec) Translate(offset Vec) Vec {
 	}
 }
 
+// Sub subtracts the given vector.
+func (v Vec) Sub(v2 Vec) Vec {
+	return Vec{
+		X: v.X - v2.X,
+		Y: v.Y - v2.Y,
+		Z: v.Z - v2.Z,
+	}
+}
+
+// Cross returns the cross product of the 2 vectors.
+func (v Vec) Cross(v2 Vec) Vec {
+	return Vec{
+		X: v.Y*v2.Z - v.Z*v2.Y,
+		Y: v.Z*v2.X - v.X*v2.Z,
+		Z: v.X*v2.Y - v.Y*v2.X,
+	}
+}
+
+// Length returns the euclidean length of the vector.
+func (v Vec) Length() float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+}
+
+// Normalize returns the unit vector with the same direction.
+// The zero vector is returned as-is.
+func (v Vec) Normalize() Vec {
+	l := v.Length()
+	if l == 0 {
+		return v
+	}
+	return v.ScaleAll(1 / l)
+}
+
 // Rotate the vector.
 func (v Vec) Rotate(angle Vec) Vec {
 	v = v.MultiplyMatrix(GetRotationMatrix(angle.Z, AxisZ))
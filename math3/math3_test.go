@@ -28,3 +28,15 @@ func TestMatMul(t *testing.T) {
 		t.Errorf("Unexpected matrix multiplication result.\nGot:      %v\nExpected: %v", got, expected)
 	}
 }
+
+func TestCross(t *testing.T) {
+	t.Parallel()
+
+	x, y := math3.Vec{X: 1}, math3.Vec{Y: 1}
+	if got, expected := x.Cross(y), (math3.Vec{Z: 1}); got != expected {
+		t.Errorf("Unexpected cross product.\nGot:      %v\nExpected: %v", got, expected)
+	}
+	if got, expected := (math3.Vec{Y: 3, Z: 4}).Normalize().Length(), 1.; got != expected {
+		t.Errorf("Unexpected normalized vector.\nGot:      %v\nExpected: %v", got, expected)
+	}
+}
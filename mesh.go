@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"go.creack.net/fdf/math3"
+)
+
+// Mesh is an indexed triangle mesh.
+type Mesh struct {
+	Vertices []math3.Vec
+	Colors   []color.RGBA // Per vertex.
+	Faces    [][3]int     // Counter-clockwise seen from above.
+}
+
+// Mesh builds the surface mesh of the current map, each grid cell being split
+// in two triangles. The X/Y spacing and the height factor are applied.
+//
+// The map is laid out with Z up and rows going toward -Y, so the mesh is not mirrored.
+// Holes, and the triangles touching them, are skipped.
+func (m *Fdf) Mesh() *Mesh {
+	mesh := &Mesh{}
+	lo, hi := m.colorRange()
+
+	// Index of each point's vertex, -1 for holes.
+	indices := make([][]int, len(m.Points))
+	for y, line := range m.Points {
+		indices[y] = make([]int, len(line))
+		for x, elem := range line {
+			if elem.IsHole() {
+				indices[y][x] = -1
+				continue
+			}
+			indices[y][x] = len(mesh.Vertices)
+			mesh.Vertices = append(mesh.Vertices, m.meshVec(elem.Vec))
+			mesh.Colors = append(mesh.Colors, m.pointColor(elem, lo, hi))
+		}
+	}
+
+	for y := 0; y+1 < len(indices); y++ {
+		for x := 0; x+1 < len(indices[y]) && x+1 < len(indices[y+1]); x++ {
+			v00, v10 := indices[y][x], indices[y][x+1]
+			v01, v11 := indices[y+1][x], indices[y+1][x+1]
+			if v00 >= 0 && v01 >= 0 && v11 >= 0 {
+				mesh.Faces = append(mesh.Faces, [3]int{v00, v01, v11})
+			}
+			if v00 >= 0 && v11 >= 0 && v10 >= 0 {
+				mesh.Faces = append(mesh.Faces, [3]int{v00, v11, v10})
+			}
+		}
+	}
+
+	return mesh
+}
+
+// meshVec converts the map vector to the mesh space: spacing and height factor applied, rows toward -Y.
+func (m *Fdf) meshVec(v math3.Vec) math3.Vec {
+	return math3.Vec{
+		X: v.X * m.cellX,
+		Y: 0 - v.Y*m.cellY, // Avoid -0.
+		Z: v.Z * m.heightFactor,
+	}
+}
+
+// normal returns the unit normal of the given face.
+func (mesh *Mesh) normal(face [3]int) math3.Vec {
+	a, b, c := mesh.Vertices[face[0]], mesh.Vertices[face[1]], mesh.Vertices[face[2]]
+	return b.Sub(a).Cross(c.Sub(a)).Normalize()
+}
+
+// SolidOptions controls the solid mesh generation.
+type SolidOptions struct {
+	// Base is the thickness of the base below the lowest point, in output units.
+	Base float64
+
+	// Size scales the mesh so its largest X/Y dimension matches it, i.e. in millimeters
+	// for 3D printing. 0 keeps the map units.
+	Size float64
+}
+
+// SolidMesh builds a closed, watertight, mesh of the current map: the surface,
+// side walls and a flat base below the lowest point.
+//
+// The mesh is moved so the base sits on Z=0 and starts at X/Y=0.
+// The map must be rectangular, without holes.
+func (m *Fdf) SolidMesh(opts SolidOptions) (*Mesh, error) {
+	if opts.Base <= 0 {
+		return nil, fmt.Errorf("invalid base thickness %v", opts.Base)
+	}
+	height := len(m.Points)
+	width := 0
+	if height > 0 {
+		width = len(m.Points[0])
+	}
+	if width < 2 || height < 2 {
+		return nil, fmt.Errorf("map too small for a solid: %dx%d", width, height)
+	}
+	for y, line := range m.Points {
+		if len(line) != width {
+			return nil, fmt.Errorf("solid requires a rectangular map, row %d has %d points instead of %d", y, len(line), width)
+		}
+		for x, elem := range line {
+			if elem.IsHole() {
+				return nil, fmt.Errorf("solid requires a map without holes, found one at %d/%d", y, x)
+			}
+		}
+	}
+
+	mesh := m.Mesh()
+
+	// Scale and move the surface so the base sits on Z=0.
+	sizeX, sizeY := float64(width-1)*m.cellX, float64(height-1)*m.cellY
+	scale := 1.
+	if opts.Size > 0 {
+		scale = opts.Size / max(sizeX, sizeY)
+	}
+	sizeX, sizeY = sizeX*scale, sizeY*scale
+	minZ := math.Inf(1)
+	for _, v := range mesh.Vertices {
+		minZ = min(minZ, v.Z)
+	}
+	// Rows go toward -Y, move them up to start at 0.
+	offset := math3.Vec{Y: sizeY, Z: opts.Base - minZ*scale}
+	for i, v := range mesh.Vertices {
+		mesh.Vertices[i] = v.ScaleAll(scale).Translate(offset)
+	}
+
+	// Walk the perimeter counter-clockwise seen from above:
+	// down the first column, along the last row, up the last column, back along the first row.
+	perimeter := make([]int, 0, 2*(width+height)-4)
+	for y := 0; y < height-1; y++ {
+		perimeter = append(perimeter, y*width)
+	}
+	for x := 0; x < width-1; x++ {
+		perimeter = append(perimeter, (height-1)*width+x)
+	}
+	for y := height - 1; y > 0; y-- {
+		perimeter = append(perimeter, y*width+width-1)
+	}
+	for x := width - 1; x > 0; x-- {
+		perimeter = append(perimeter, x)
+	}
+
+	// Base vertices under each perimeter point, plus the center of the base.
+	baseStart := len(mesh.Vertices)
+	for _, idx := range perimeter {
+		v := mesh.Vertices[idx]
+		mesh.Vertices = append(mesh.Vertices, math3.Vec{X: v.X, Y: v.Y})
+		mesh.Colors = append(mesh.Colors, mesh.Colors[idx])
+	}
+	center := len(mesh.Vertices)
+	mesh.Vertices = append(mesh.Vertices, math3.Vec{X: sizeX / 2, Y: sizeY / 2})
+	mesh.Colors = append(mesh.Colors, defaultColor)
+
+	for i, a := range perimeter {
+		j := (i + 1) % len(perimeter)
+		b, baseA, baseB := perimeter[j], baseStart+i, baseStart+j
+		// Walls, facing out.
+		mesh.Faces = append(mesh.Faces, [3]int{a, baseA, baseB}, [3]int{a, baseB, b})
+		// Base, facing down.
+		mesh.Faces = append(mesh.Faces, [3]int{center, baseB, baseA})
+	}
+
+	return mesh, nil
+}
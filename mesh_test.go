@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMesh(t *testing.T) {
+	t.Parallel()
+
+	points, err := parseMap(strings.NewReader("0 0 0\n0 5 0\n0 0 0\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	points[2][2].Z = math.NaN() // Hole in a corner.
+	m := &Fdf{Points: points, heightFactor: 2, cellX: 10, cellY: 10}
+
+	mesh := m.Mesh()
+	if len(mesh.Vertices) != 8 {
+		t.Fatalf("Unexpected vertex count %d.", len(mesh.Vertices))
+	}
+	// 4 cells, 2 triangles each, minus the 2 sharing the hole's diagonal.
+	if len(mesh.Faces) != 6 {
+		t.Fatalf("Unexpected face count %d.", len(mesh.Faces))
+	}
+	if v := mesh.Vertices[4]; v.X != 10 || v.Y != -10 || v.Z != 10 {
+		t.Fatalf("Unexpected center vertex %v.", v)
+	}
+	for _, f := range mesh.Faces {
+		if n := mesh.normal(f); n.Z <= 0 {
+			t.Fatalf("Face %v should face up, got normal %v.", f, n)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := writeSTL(buf, mesh); err != nil {
+		t.Fatalf("writeSTL: %s.", err)
+	}
+	if n := binary.LittleEndian.Uint32(buf.Bytes()[80:]); n != 6 || buf.Len() != 84+50*6 {
+		t.Fatalf("Unexpected STL: %d faces, %d bytes.", n, buf.Len())
+	}
+}
+
+func TestSolidMeshWatertight(t *testing.T) {
+	t.Parallel()
+
+	points, err := parseMap(strings.NewReader("0 1 2 3\n-4 5 6 7\n8 9 1 1\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parseMap: %s.", err)
+	}
+	m := &Fdf{Points: points, heightFactor: 1, cellX: 2, cellY: 1}
+
+	mesh, err := m.SolidMesh(SolidOptions{Base: 2, Size: 60})
+	if err != nil {
+		t.Fatalf("SolidMesh: %s.", err)
+	}
+
+	// Closed manifold: each directed edge is used exactly once, along with its reverse.
+	edges := map[[2]int]int{}
+	for _, f := range mesh.Faces {
+		for i := range f {
+			edges[[2]int{f[i], f[(i+1)%3]}]++
+		}
+	}
+	for e, n := range edges {
+		if n != 1 || edges[[2]int{e[1], e[0]}] != 1 {
+			t.Fatalf("Edge %v is not manifold: %d/%d.", e, n, edges[[2]int{e[1], e[0]}])
+		}
+	}
+
+	minZ, maxX := math.Inf(1), math.Inf(-1)
+	for _, v := range mesh.Vertices {
+		minZ, maxX = math.Min(minZ, v.Z), math.Max(maxX, v.X)
+	}
+	if minZ != 0 || maxX != 60 {
+		t.Fatalf("Unexpected bounds: min Z %v, max X %v.", minZ, maxX)
+	}
+
+	// The lowest point (-4) is 2 above the base.
+	if z := mesh.Vertices[4].Z; z != 2 {
+		t.Fatalf("Unexpected lowest point height %v.", z)
+	}
+
+	points[1] = points[1][:3]
+	if _, err := m.SolidMesh(SolidOptions{Base: 2}); err == nil {
+		t.Fatal("Expected error for ragged map.")
+	}
+}
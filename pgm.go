@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+)
+
+// decodePGM decodes a Netpbm grayscale image, either plain (P2) or raw (P5).
+//
+// Images with a max value above 255 are decoded as *image.Gray16 to keep the precision,
+// otherwise as *image.Gray. Values are rescaled to the full range.
+func decodePGM(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := pgmToken(br)
+	if err != nil {
+		return nil, fmt.Errorf("read magic: %w", err)
+	}
+	if magic != "P2" && magic != "P5" {
+		return nil, fmt.Errorf("unsupported netpbm magic %q", magic)
+	}
+
+	var header [3]int // Width, height, max value.
+	for i := range header {
+		tok, err := pgmToken(br)
+		if err != nil {
+			return nil, fmt.Errorf("read header: %w", err)
+		}
+		if header[i], err = strconv.Atoi(tok); err != nil || header[i] <= 0 {
+			return nil, fmt.Errorf("invalid header value %q", tok)
+		}
+	}
+	width, height, maxVal := header[0], header[1], header[2]
+	if maxVal > 0xFFFF {
+		return nil, fmt.Errorf("invalid max value %d", maxVal)
+	}
+	if width > maxImageSide || height > maxImageSide {
+		return nil, fmt.Errorf("image too large: %dx%d", width, height)
+	}
+
+	// Grow the pixels while reading rather than trusting the header,
+	// so a truncated file can't trigger a huge allocation.
+	var pix []byte
+	sampleSize := 1
+	if maxVal > 0xFF {
+		sampleSize = 2
+	}
+	sample := make([]byte, sampleSize)
+	for i := 0; i < width*height; i++ {
+		var v int
+		if magic == "P2" {
+			tok, err := pgmToken(br)
+			if err != nil {
+				return nil, fmt.Errorf("read sample %d: %w", i, err)
+			}
+			if v, err = strconv.Atoi(tok); err != nil {
+				return nil, fmt.Errorf("invalid sample %q: %w", tok, err)
+			}
+		} else {
+			if _, err := io.ReadFull(br, sample); err != nil {
+				return nil, fmt.Errorf("read sample %d: %w", i, err)
+			}
+			if sampleSize == 2 {
+				v = int(binary.BigEndian.Uint16(sample))
+			} else {
+				v = int(sample[0])
+			}
+		}
+		if v < 0 || v > maxVal {
+			return nil, fmt.Errorf("sample %d out of range: %d", i, v)
+		}
+		pix = binary.BigEndian.AppendUint16(pix, uint16(v*0xFFFF/maxVal))
+	}
+	img := &image.Gray16{Pix: pix, Stride: 2 * width, Rect: image.Rect(0, 0, width, height)}
+
+	if sampleSize == 1 { // No need to keep 16 bits.
+		gray := image.NewGray(img.Bounds())
+		for i := range gray.Pix {
+			gray.Pix[i] = img.Pix[2*i]
+		}
+		return gray, nil
+	}
+	return img, nil
+}
+
+// pgmToken reads the next whitespace separated token, skipping comments.
+func pgmToken(br *bufio.Reader) (string, error) {
+	var tok []byte
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			if len(tok) > 0 && errors.Is(err, io.EOF) {
+				return string(tok), nil
+			}
+			return "", err
+		}
+		switch {
+		case c == '#' && len(tok) == 0:
+			if _, err := br.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if len(tok) > 0 {
+				return string(tok), nil
+			}
+		default:
+			tok = append(tok, c)
+		}
+	}
+}
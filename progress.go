@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// progressStep is the number of bytes read between two progress reports.
+const progressStep = 1 << 20
+
+// ProgressFunc is called with the number of bytes read so far and
+// the total size, -1 when unknown (i.e. stdin).
+type ProgressFunc func(read, total int64)
+
+// progressReader wraps a reader and reports the progress every progressStep bytes.
+type progressReader struct {
+	r  io.Reader
+	fn ProgressFunc
+
+	read, total, next int64
+}
+
+// newProgressReader wraps r to report progress to fn.
+// Returns r as-is if fn is nil.
+func newProgressReader(r io.Reader, total int64, fn ProgressFunc) io.Reader {
+	if fn == nil {
+		return r
+	}
+	return &progressReader{r: r, fn: fn, total: total, next: progressStep}
+}
+
+// Read implements io.Reader.
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.read += int64(n)
+	if pr.read >= pr.next || (errors.Is(err, io.EOF) && pr.read > progressStep) {
+		pr.fn(pr.read, pr.total)
+		pr.next = pr.read + progressStep
+	}
+	return n, err
+}
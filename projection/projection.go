@@ -21,6 +21,12 @@ var (
 	}
 )
 
+// View is a camera preset, i.e. from a map header.
+type View struct {
+	Angle *math3.Vec // Nil to keep the default camera rotation.
+	Scale int        // 0 to fit the screen.
+}
+
 // Projection defines how to project the given 3d point.
 type Projection interface {
 	Project(math3.Vec) math3.Vec
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Ramp is a color ramp, the colors being evenly spread from the lowest to the highest point.
+type Ramp []color.RGBA
+
+//nolint:gochecknoglobals // Expected "readonly" global.
+var ramps = map[string]Ramp{
+	"grayscale": {{0x20, 0x20, 0x20, 0xFF}, {0xFF, 0xFF, 0xFF, 0xFF}},
+	"terrain": {
+		{0x1F, 0x4E, 0x9C, 0xFF}, // Water.
+		{0x3A, 0x9A, 0x4A, 0xFF}, // Lowlands.
+		{0xE8, 0xD6, 0x6B, 0xFF},
+		{0x8C, 0x5A, 0x2B, 0xFF}, // Mountains.
+		{0xFF, 0xFF, 0xFF, 0xFF}, // Snow.
+	},
+	"diverging": {{0x21, 0x66, 0xAC, 0xFF}, {0xF7, 0xF7, 0xF7, 0xFF}, {0xB2, 0x18, 0x2B, 0xFF}}, // Blue, white, red.
+	"heat":      {{0x00, 0x00, 0x00, 0xFF}, {0xD0, 0x10, 0x10, 0xFF}, {0xFF, 0xD0, 0x20, 0xFF}, {0xFF, 0xFF, 0xFF, 0xFF}},
+
+	// Perceptually uniform, readable with color vision deficiencies and in grayscale.
+	"viridis": {
+		{0x44, 0x01, 0x54, 0xFF}, {0x47, 0x2D, 0x7B, 0xFF}, {0x3B, 0x52, 0x8B, 0xFF},
+		{0x2C, 0x72, 0x8E, 0xFF}, {0x21, 0x91, 0x8C, 0xFF}, {0x28, 0xAE, 0x80, 0xFF},
+		{0x5E, 0xC9, 0x62, 0xFF}, {0xAD, 0xDC, 0x30, 0xFF}, {0xFD, 0xE7, 0x25, 0xFF},
+	},
+	// Blue to yellow, optimized for the red/green color vision deficiencies.
+	"cividis": {
+		{0x00, 0x22, 0x4E, 0xFF}, {0x12, 0x35, 0x70, 0xFF}, {0x3B, 0x49, 0x6C, 0xFF},
+		{0x57, 0x5D, 0x6D, 0xFF}, {0x70, 0x71, 0x73, 0xFF}, {0x8A, 0x86, 0x78, 0xFF},
+		{0xA5, 0x9C, 0x74, 0xFF}, {0xC3, 0xB3, 0x69, 0xFF}, {0xE1, 0xCC, 0x55, 0xFF},
+		{0xFE, 0xE8, 0x38, 0xFF},
+	},
+	// Diverging, without red/green, for the color vision deficiencies.
+	"purple-orange": {
+		{0x54, 0x27, 0x88, 0xFF}, {0x99, 0x8E, 0xC3, 0xFF}, {0xD8, 0xDA, 0xEB, 0xFF},
+		{0xF7, 0xF7, 0xF7, 0xFF},
+		{0xFE, 0xE0, 0xB6, 0xFF}, {0xF1, 0xA3, 0x40, 0xFF}, {0xB3, 0x58, 0x06, 0xFF},
+	},
+}
+
+// ParseRamp parses the ramp: either a built-in ramp name, see RampNames,
+// or a comma separated list of at least 2 colors, evenly spread, i.e. "0x0000FF,0xFFFFFF,0xFF0000".
+// An empty spec returns a nil ramp.
+func ParseRamp(spec string) (Ramp, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if r, ok := ramps[spec]; ok {
+		return r, nil
+	}
+	if !strings.Contains(spec, ",") {
+		return nil, fmt.Errorf("unknown palette %q, expected a color list or one of %s", spec, strings.Join(RampNames(), ", "))
+	}
+	var r Ramp
+	for _, elem := range strings.Split(spec, ",") {
+		c, err := rgbaFromHexString(strings.TrimSpace(elem))
+		if err != nil {
+			return nil, fmt.Errorf("invalid palette color %q: %w", elem, err)
+		}
+		r = append(r, c)
+	}
+	return r, nil
+}
+
+// RampNames returns the sorted names of the built-in ramps.
+func RampNames() []string {
+	names := make([]string, 0, len(ramps))
+	for name := range ramps {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// At returns the color at the given position, between 0 and 1.
+func (r Ramp) At(position float64) color.RGBA {
+	if len(r) == 0 {
+		return defaultColor
+	}
+	if math.IsNaN(position) { // Flat map.
+		position = 0
+	}
+	position = math.Max(0, math.Min(1, position))
+	pos := position * float64(len(r)-1)
+	i := int(pos)
+	if i >= len(r)-1 {
+		return r[len(r)-1]
+	}
+	return getGradientColor(r[i], r[i+1], pos-float64(i), ColorSpaceSRGB)
+}
+
+// SetPalette sets the palette, see ParseRamp, overriding the maps' header.
+// An empty spec keeps the point colors.
+func (m *Fdf) SetPalette(spec string) error {
+	r, err := ParseRamp(spec)
+	if err != nil {
+		return err
+	}
+	m.palette, m.paletteSet, m.ramp = spec, true, r
+	return nil
+}
+
+// Palette returns the current palette, empty if none.
+func (m *Fdf) Palette() string { return m.palette }
+
+// PaletteNames returns the names of the built-in palettes.
+func (m *Fdf) PaletteNames() []string { return RampNames() }
+
+// SetIgnoreColors sets whether the palette applies to the points with explicit color as well.
+func (m *Fdf) SetIgnoreColors(ignore bool) { m.ignoreColors = ignore }
+
+// heightRange returns the lowest and highest heights of the map, ignoring the holes.
+func heightRange(points [][]MapPoint) (lo, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, line := range points {
+		for _, elem := range line {
+			if elem.IsHole() {
+				continue
+			}
+			lo, hi = math.Min(lo, elem.Z), math.Max(hi, elem.Z)
+		}
+	}
+	return lo, hi
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"go.creack.net/fdf/projection"
+	"go.creack.net/fdf/render"
+)
+
+func TestParseRamp(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range RampNames() {
+		r, err := ParseRamp(name)
+		if err != nil {
+			t.Fatalf("ParseRamp %q: %s.", name, err)
+		}
+		if len(r) < 2 {
+			t.Errorf("Unexpected ramp length for %q: %d.", name, len(r))
+		}
+	}
+
+	r, err := ParseRamp("0x0000FF, 0xFF0000")
+	if err != nil {
+		t.Fatalf("ParseRamp: %s.", err)
+	}
+	blue, red := color.RGBA{B: 0xFF, A: 0xFF}, color.RGBA{R: 0xFF, A: 0xFF}
+	if r.At(0) != blue || r.At(1) != red {
+		t.Errorf("Unexpected custom ramp ends.\nGot:      %v, %v\nExpected: %v, %v", r.At(0), r.At(1), blue, red)
+	}
+
+	// Same digit count rule as the map colors.
+	r, err = ParseRamp("0x0000FF80,0xFF0000")
+	if err != nil {
+		t.Fatalf("ParseRamp: %s.", err)
+	}
+	if got, expect := r.At(0), (color.RGBA{B: 0x80, A: 0x80}); got != expect {
+		t.Errorf("Unexpected translucent ramp start.\nGot:      %v\nExpected: %v", got, expect)
+	}
+
+	if r, err := ParseRamp(""); err != nil || r != nil {
+		t.Errorf("Unexpected empty ramp: %v, %v.", r, err)
+	}
+	for _, spec := range []string{"bogus", "0xFF0000", "0xFF0000,", "0xFF0000,nope"} {
+		if _, err := ParseRamp(spec); err == nil {
+			t.Errorf("Expected error for %q.", spec)
+		}
+	}
+}
+
+func TestPalette(t *testing.T) {
+	t.Parallel()
+
+	// Explicit white is kept as-is, not taken for the default color.
+	m, err := readMap(strings.NewReader("# palette: grayscale\n0 5,0xFFFFFF\n10 10,0xFF0000\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("readMap: %s.", err)
+	}
+	g := &Fdf{projection: projection.NewDirect(), heightFactor: 1}
+	g.setMap(m)
+	gray := ramps["grayscale"]
+
+	colors := func() []color.RGBA {
+		lo, hi := g.colorRange()
+		return []color.RGBA{g.pointColor(m.Points[0][0], lo, hi), g.pointColor(m.Points[0][1], lo, hi), g.pointColor(m.Points[1][1], lo, hi)}
+	}
+	assertColors := func(name string, expect ...color.RGBA) {
+		t.Helper()
+		got := colors()
+		for i := range expect {
+			if got[i] != expect[i] {
+				t.Errorf("Unexpected colors with %s.\nGot:      %v\nExpected: %v", name, got, expect)
+				return
+			}
+		}
+	}
+	assertColors("header palette", gray.At(0), defaultColor, color.RGBA{R: 0xFF, A: 0xFF})
+
+	g.SetIgnoreColors(true)
+	assertColors("ignored colors", gray.At(0), gray.At(0.5), gray.At(1))
+	g.SetIgnoreColors(false)
+
+	// The palette set on the engine overrides the header's, even after a reload.
+	if err := g.SetPalette("0x000000,0x0000FF"); err != nil {
+		t.Fatalf("SetPalette: %s.", err)
+	}
+	g.setMap(m)
+	if p := g.Map().Meta.Palette; p != "0x000000,0x0000FF" {
+		t.Errorf("Unexpected map palette %q.", p)
+	}
+	assertColors("custom palette", color.RGBA{A: 0xFF}, defaultColor)
+
+	if err := g.SetPalette(""); err != nil {
+		t.Fatalf("SetPalette: %s.", err)
+	}
+	assertColors("no palette", defaultColor, defaultColor, color.RGBA{R: 0xFF, A: 0xFF})
+
+	// Without palette, the points without explicit color take the theme's edge color.
+	light, err := render.LookupTheme("light")
+	if err != nil {
+		t.Fatalf("LookupTheme: %s.", err)
+	}
+	g.SetTheme(light)
+	assertColors("light theme", light.Edge, defaultColor, color.RGBA{R: 0xFF, A: 0xFF})
+
+	if err := g.SetPalette("bogus"); err == nil {
+		t.Error("Expected error for unknown palette.")
+	}
+}
@@ -4,6 +4,12 @@ package ebitenrenderer
 import (
 	"fmt"
 	"image"
+	"image/color"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
 
 	"go.creack.net/fdf/math3"
 	"go.creack.net/fdf/projection"
@@ -25,8 +31,26 @@ type Game struct {
 	offset math3.Vec // Offset of the rendered image.
 
 	tainted bool // Flag to know when to redraw img.
+
+	watch     time.Duration // Interval between two checks of the map file, 0 to disable.
+	lastCheck time.Time
+	mapStat   fs.FileInfo // Map file info at the last (re)load.
+	reloadErr error       // Error of the last reload, if any, shown in the HUD.
+
+	background      *ebiten.Image // Cached background gradient, see drawBackground.
+	backgroundTheme render.Theme  // Theme of the cached background.
+	hud             *ebiten.Image // Offscreen image to tint the HUD, see drawText.
+}
+
+// hudLine is a block of HUD text with its color.
+type hudLine struct {
+	text  string
+	color color.RGBA
 }
 
+// gradientSteps is the height of the cached background gradient, stretched to the screen.
+const gradientSteps = 256
+
 // Update implements the ebiten interface.
 //
 // Handles key presses.
@@ -36,23 +60,106 @@ func (g *Game) Update() error {
 	g.handleFdfKeys(g.keys)
 
 	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
-		entries := g.fdf.ListMaps()
-		i := -1
-		for ii, elem := range entries {
-			if elem.Name() == g.fdf.CurrentMapName() {
-				i = ii
-				break
-			}
+		if err := g.cycleMap(); err != nil {
+			return fmt.Errorf("cycleMap: %w", err)
 		}
-		if err := g.fdf.LoadMap("maps/" + entries[(i+1)%len(entries)].Name()); err != nil {
-			return fmt.Errorf("loadMap: %w", err)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		if err := g.cyclePalette(); err != nil {
+			return fmt.Errorf("cyclePalette: %w", err)
 		}
-		g.img = image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyX) {
+		g.fdf.SetAntialias(!g.fdf.Antialias())
 		g.tainted = true
 	}
+
+	if g.watch > 0 && time.Since(g.lastCheck) >= g.watch {
+		g.lastCheck = time.Now()
+		g.reloadIfChanged()
+	}
+
+	// F1-F9 toggle the filters.
+	for i, k := range []ebiten.Key{ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4, ebiten.KeyF5, ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8, ebiten.KeyF9} {
+		if i < len(g.fdf.FilterNames()) && inpututil.IsKeyJustPressed(k) {
+			g.fdf.ToggleFilter(i)
+			g.tainted = true
+		}
+	}
 	return nil
 }
 
+// cycleMap loads the next map from the current map's directory.
+func (g *Game) cycleMap() error {
+	entries := g.fdf.ListMaps()
+	if len(entries) == 0 { // Nothing to cycle through, i.e. map from stdin.
+		return nil
+	}
+	dir, name := path.Split(g.fdf.CurrentMapPath())
+	i := -1
+	for ii, elem := range entries {
+		if elem.Name() == name {
+			i = ii
+			break
+		}
+	}
+	if err := g.fdf.LoadMap(path.Join(dir, entries[(i+1)%len(entries)].Name())); err != nil {
+		return fmt.Errorf("loadMap: %w", err)
+	}
+	g.img = image.NewRGBA(image.Rect(0, 0, 0, 0))
+	g.tainted = true
+	g.mapStat, _ = g.fdf.CurrentMapStat() // Best effort, see reloadIfChanged.
+	g.reloadErr = nil
+	return nil
+}
+
+// cyclePalette sets the next built-in palette, none being after the last one.
+// A custom palette is followed by the first built-in one.
+func (g *Game) cyclePalette() error {
+	names := append(g.fdf.PaletteNames(), "")
+	i := slices.Index(names, g.fdf.Palette()) // -1 for a custom palette, so starts over.
+	if err := g.fdf.SetPalette(names[(i+1)%len(names)]); err != nil {
+		return fmt.Errorf("setPalette: %w", err)
+	}
+	g.tainted = true
+	return nil
+}
+
+// reloadIfChanged reloads the current map if its file changed since the last load,
+// keeping the camera, scale and height factor.
+//
+// On failure, the current map is kept and the error is shown in the HUD.
+func (g *Game) reloadIfChanged() {
+	st, reloaded, err := render.ReloadIfChanged(g.fdf, g.mapStat)
+	g.mapStat = st
+	if err != nil {
+		g.reloadErr = err
+		return
+	}
+	if reloaded {
+		g.reloadErr = nil
+		g.tainted = true
+	}
+}
+
+// paletteName returns the name of the current palette, "none" if not set.
+func (g *Game) paletteName() string {
+	if name := g.fdf.Palette(); name != "" {
+		return name
+	}
+	return "none"
+}
+
+// reloadStatus returns the error of the last reload, if any.
+func (g *Game) reloadStatus() string {
+	if g.reloadErr == nil {
+		return ""
+	}
+	return fmt.Sprintf("Reload failed, keeping the previous map:\n  %s\n", g.reloadErr)
+}
+
 func (g *Game) handleFdfKeys(keys []ebiten.Key) {
 	p := g.fdf.GetProjection()
 	scale := p.GetScale()
@@ -147,6 +254,8 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	// Translate to local offset (controlled by keyboard).
 	op.GeoM.Translate(g.offset.X, g.offset.Y)
 
+	theme := g.fdf.Theme()
+	g.drawBackground(screen, theme)
 	screen.DrawImage(ebiten.NewImageFromImage(g.img), op)
 
 	msg := fmt.Sprintf(`TPS: %0.2f
@@ -170,38 +279,125 @@ Camera:
 		g.fdf.GetProjection().GetAngle().Y,
 		g.fdf.GetProjection().GetAngle().Z,
 	)
-	ebitenutil.DebugPrintAt(screen, msg, screenWidth-150, 1)
-	ebitenutil.DebugPrint(screen, fmt.Sprintf(`TPS: %0.2f, FPS: %0.2f
+	g.drawText(screen, []hudLine{{text: msg, color: theme.Text}}, screenWidth-150, 1)
+
+	lines := []hudLine{{text: fmt.Sprintf(`TPS: %0.2f, FPS: %0.2f
 Resolution: %dx%d
 Map: %s
-
+Palette: %s
+Anti-aliasing: %t
+`, ebiten.ActualTPS(), ebiten.ActualFPS(), g.screenWidth, g.screenHeight, g.fdf.CurrentMapName(), g.paletteName(), g.fdf.Antialias()), color: theme.Text}}
+	lines = append(lines, hudLine{text: g.reloadStatus(), color: theme.Warning})
+	lines = append(lines, g.filtersStatus(theme)...)
+	lines = append(lines, hudLine{text: `
 Controls:
   W/A/S/D: Move
   Up/Down/Left/Right/Shift Left/Shift Right: Rotate
   C: Cycle maps
+  P: Cycle palettes
+  X: Toggle anti-aliasing
   I: Reset view to Isometric
   0: Reset view to 0 angles.
   1/2: Change height scale factor
   3/4: Zoom in/out
-`, ebiten.ActualTPS(), ebiten.ActualFPS(), g.screenWidth, g.screenHeight, g.fdf.CurrentMapName()))
+  F1-F9: Toggle filters
+`, color: theme.Text})
+	g.drawText(screen, lines, 0, 0)
+}
+
+// drawBackground fills the screen with the theme's background, the rendered image being transparent.
+func (g *Game) drawBackground(screen *ebiten.Image, theme render.Theme) {
+	if !theme.HasGradient() {
+		screen.Fill(theme.Background)
+		return
+	}
+	if g.background == nil || g.backgroundTheme != theme {
+		g.background = ebiten.NewImage(1, gradientSteps)
+		for y := 0; y < gradientSteps; y++ {
+			g.background.Set(0, y, theme.BackgroundAt(float64(y)/(gradientSteps-1)))
+		}
+		g.backgroundTheme = theme
+	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(screen.Bounds().Dx()), float64(screen.Bounds().Dy())/gradientSteps)
+	op.Filter = ebiten.FilterLinear
+	screen.DrawImage(g.background, op)
+}
+
+// drawText prints the lines at the given position, each with its color.
+// The debug font being white, each color is printed offscreen then tinted,
+// the lines of the other colors left blank to keep the layout.
+func (g *Game) drawText(screen *ebiten.Image, lines []hudLine, x, y int) {
+	if g.hud == nil || g.hud.Bounds() != screen.Bounds() {
+		g.hud = ebiten.NewImage(screen.Bounds().Dx(), screen.Bounds().Dy())
+	}
+	var colors []color.RGBA
+	for _, elem := range lines {
+		if elem.text != "" && !slices.Contains(colors, elem.color) {
+			colors = append(colors, elem.color)
+		}
+	}
+	for _, c := range colors {
+		var buf strings.Builder
+		for _, elem := range lines {
+			if elem.color == c {
+				buf.WriteString(elem.text)
+			} else {
+				buf.WriteString(strings.Repeat("\n", strings.Count(elem.text, "\n")))
+			}
+		}
+		g.hud.Clear()
+		ebitenutil.DebugPrintAt(g.hud, buf.String(), x, y)
+		op := &ebiten.DrawImageOptions{}
+		op.ColorScale.ScaleWithColor(c)
+		screen.DrawImage(g.hud, op)
+	}
+}
+
+// filtersStatus lists the filters with their state and toggle key, the enabled ones highlighted.
+func (g *Game) filtersStatus(theme render.Theme) []hudLine {
+	names := g.fdf.FilterNames()
+	if len(names) == 0 {
+		return nil
+	}
+	lines := []hudLine{{text: "Filters:\n", color: theme.Text}}
+	for i, name := range names {
+		line := hudLine{text: fmt.Sprintf("  F%d: [ ] %s\n", i+1, name), color: theme.Text}
+		if g.fdf.FilterEnabled(i) {
+			line = hudLine{text: fmt.Sprintf("  F%d: [x] %s\n", i+1, name), color: theme.Highlight}
+		}
+		lines = append(lines, line)
+	}
+	return lines
 }
 
 // Layout implements the ebiten.Game interface.
 func (g *Game) Layout(outsideWidth, outsideHeight int) (w, h int) { return outsideWidth, outsideHeight }
 
-type renderer struct{}
+// Options controls the renderer.
+type Options struct {
+	// Watch, when set, is the interval at which the map file is checked for changes.
+	// A changed map is reloaded, keeping the current view.
+	Watch time.Duration
+}
+
+type renderer struct {
+	opts Options
+}
 
 // New creates the renderer.
-func New(initialWidth, initialHeight int) render.Renderer {
+func New(initialWidth, initialHeight int, opts Options) render.Renderer {
 	ebiten.SetWindowSize(initialWidth*2, initialHeight*2)
 	ebiten.SetWindowTitle("FDF")
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 
-	return &renderer{}
+	return &renderer{opts: opts}
 }
 
 func (r *renderer) Run(fdf render.Engine) error {
-	g := &Game{fdf: fdf}
+	g := &Game{fdf: fdf, watch: r.opts.Watch, lastCheck: time.Now()}
+	g.mapStat, _ = fdf.CurrentMapStat() // Best effort, see reloadIfChanged.
+	fdf.SetTransparentBackground(true)  // The background is drawn on screen, see drawBackground.
 
 	if err := ebiten.RunGame(g); err != nil {
 		return fmt.Errorf("runGame: %w", err)
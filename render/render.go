@@ -2,6 +2,7 @@
 package render
 
 import (
+	"fmt"
 	"image"
 	"io/fs"
 
@@ -17,33 +18,90 @@ type Renderer interface {
 type Engine interface {
 	SetProjection(projection.Projection) image.Rectangle
 	GetProjection() projection.Projection
+	DefaultView() projection.View
 
 	GetHeightFactor() float64
 	SetHeightFactor(float64)
 
 	Draw() image.Image
+	Theme() Theme
+	SetTransparentBackground(bool)
+	Antialias() bool
+	SetAntialias(bool)
+
+	FilterNames() []string
+	FilterEnabled(int) bool
+	ToggleFilter(int)
+
+	Palette() string
+	PaletteNames() []string
+	SetPalette(string) error
 
 	CurrentMapName() string
+	CurrentMapPath() string
+	CurrentMapStat() (fs.FileInfo, error)
 	ListMaps() []fs.DirEntry
 	LoadMap(string) error
 }
 
 // Iso is a helper function to initialize an isomorphic projection.
+//
+// The engine's default view, if any, overrides the camera rotation and the scale.
 func Iso(fdf Engine, screenWidth, screenHeight int) image.Rectangle {
+	view := fdf.DefaultView()
+
 	// Initialize a projection with scale 1 to get base boundaries.
 	p := projection.NewIsomorphic(1)
+	if view.Angle != nil {
+		p.SetAngle(*view.Angle)
+	}
 
 	// Set the projection on the engine, returns the initial bounds.
 	bounds := fdf.SetProjection(p)
 
 	// Compute the scale from the initial bounds.
-	scale := projection.GetScale(screenHeight, screenWidth, bounds)
+	scale := view.Scale
+	if scale <= 0 {
+		scale = projection.GetScale(screenHeight, screenWidth, bounds)
+	}
 
 	// Create the final projection with the new scale.
 	p = projection.NewIsomorphic(scale)
+	if view.Angle != nil {
+		p.SetAngle(*view.Angle)
+	}
 
 	// Set the projection on the engine, returns the final bounds.
 	bounds = fdf.SetProjection(p)
 
 	return bounds
 }
+
+// ReloadIfChanged reloads the engine's current map if its file changed since last,
+// keeping the current view: scale, angles and height factor.
+//
+// Returns the file info to compare with on the next call and whether the map got reloaded.
+// On error, the previous map is kept.
+func ReloadIfChanged(fdf Engine, last fs.FileInfo) (fs.FileInfo, bool, error) {
+	st, err := fdf.CurrentMapStat()
+	if err != nil { // No backing file, or being replaced. Check again later.
+		return last, false, nil
+	}
+	if last != nil && st.ModTime().Equal(last.ModTime()) && st.Size() == last.Size() {
+		return last, false, nil
+	}
+
+	// Loading the map applies its header's view, save the current one to restore it.
+	p := fdf.GetProjection()
+	scale, angle, heightFactor := p.GetScale(), p.GetAngle(), fdf.GetHeightFactor()
+
+	if err := fdf.LoadMap(fdf.CurrentMapPath()); err != nil {
+		return st, false, fmt.Errorf("reload %q: %w", fdf.CurrentMapPath(), err)
+	}
+
+	p.SetScale(scale)
+	p.SetAngle(angle)
+	fdf.SetHeightFactor(heightFactor)
+	fdf.SetProjection(p) // Update the offset for the new map's bounds.
+	return st, true, nil
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Theme is the style of the rendering, shared by the engine and the renderers.
+type Theme struct {
+	Name string
+
+	// Background is the color at the top of the canvas, BackgroundBottom at the bottom,
+	// blended vertically. The same color for a plain background.
+	Background, BackgroundBottom color.RGBA
+
+	// Edge is the color of the points without explicit color nor palette.
+	Edge color.RGBA
+	// LineWidth is the thickness of the edges, in pixels.
+	LineWidth int
+
+	// Text is the color of the HUD.
+	Text color.RGBA
+	// Highlight is the color of the HUD elements to notice, i.e. the enabled filters.
+	Highlight color.RGBA
+	// Warning is the color of the HUD errors, i.e. a failed reload.
+	Warning color.RGBA
+}
+
+//nolint:gochecknoglobals // Expected "readonly" global.
+var themes = []Theme{
+	{
+		Name:             "dark",
+		Background:       color.RGBA{A: 0xFF},
+		BackgroundBottom: color.RGBA{A: 0xFF},
+		Edge:             color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		LineWidth:        1,
+		Text:             color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		Highlight:        color.RGBA{R: 0x40, G: 0xD0, B: 0x40, A: 0xFF},
+		Warning:          color.RGBA{R: 0xFF, G: 0x50, B: 0x40, A: 0xFF},
+	},
+	{
+		// Light with thicker edges, for printing.
+		Name:             "light",
+		Background:       color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		BackgroundBottom: color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		Edge:             color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xFF},
+		LineWidth:        2,
+		Text:             color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xFF},
+		Highlight:        color.RGBA{R: 0x10, G: 0x60, B: 0xC0, A: 0xFF},
+		Warning:          color.RGBA{R: 0xC0, G: 0x10, B: 0x10, A: 0xFF},
+	},
+	{
+		Name:             "blueprint",
+		Background:       color.RGBA{R: 0x12, G: 0x4A, B: 0x9E, A: 0xFF},
+		BackgroundBottom: color.RGBA{R: 0x08, G: 0x26, B: 0x5C, A: 0xFF},
+		Edge:             color.RGBA{R: 0xE0, G: 0xF0, B: 0xFF, A: 0xFF},
+		LineWidth:        1,
+		Text:             color.RGBA{R: 0xE0, G: 0xF0, B: 0xFF, A: 0xFF},
+		Highlight:        color.RGBA{R: 0xFF, G: 0xE0, B: 0x40, A: 0xFF},
+		Warning:          color.RGBA{R: 0xFF, G: 0x80, B: 0x60, A: 0xFF},
+	},
+}
+
+// DefaultTheme returns the default theme: white on black.
+func DefaultTheme() Theme { return themes[0] }
+
+// ThemeNames returns the names of the built-in themes, the default one first.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for _, elem := range themes {
+		names = append(names, elem.Name)
+	}
+	return names
+}
+
+// LookupTheme returns the built-in theme with the given name.
+func LookupTheme(name string) (Theme, error) {
+	i := slices.IndexFunc(themes, func(t Theme) bool { return t.Name == name })
+	if i < 0 {
+		return Theme{}, fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(ThemeNames(), ", "))
+	}
+	return themes[i], nil
+}
+
+// HasGradient returns whether the background is a gradient rather than a plain color.
+func (t Theme) HasGradient() bool { return t.Background != t.BackgroundBottom }
+
+// BackgroundAt returns the background color at the given height of the canvas,
+// between 0 at the top and 1 at the bottom.
+func (t Theme) BackgroundAt(position float64) color.RGBA {
+	position = math.Max(0, math.Min(1, position))
+	mix := func(a, b uint8) uint8 { return uint8(math.Round(float64(a)*(1-position) + float64(b)*position)) }
+	return color.RGBA{
+		R: mix(t.Background.R, t.BackgroundBottom.R),
+		G: mix(t.Background.G, t.BackgroundBottom.G),
+		B: mix(t.Background.B, t.BackgroundBottom.B),
+		A: mix(t.Background.A, t.BackgroundBottom.A),
+	}
+}
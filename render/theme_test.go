@@ -0,0 +1,58 @@
+package render_test
+
+import (
+	"image/color"
+	"testing"
+
+	"go.creack.net/fdf/render"
+)
+
+func TestLookupTheme(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range render.ThemeNames() {
+		theme, err := render.LookupTheme(name)
+		if err != nil {
+			t.Fatalf("LookupTheme(%q): %s.", name, err)
+		}
+		if theme.Name != name {
+			t.Errorf("Unexpected theme name.\nGot:      %q\nExpected: %q", theme.Name, name)
+		}
+		if theme.LineWidth < 1 {
+			t.Errorf("Unexpected line width for %q: %d.", name, theme.LineWidth)
+		}
+	}
+	if got, expect := render.DefaultTheme().Name, "dark"; got != expect {
+		t.Errorf("Unexpected default theme.\nGot:      %q\nExpected: %q", got, expect)
+	}
+	if _, err := render.LookupTheme("bogus"); err == nil {
+		t.Error("Expected error for unknown theme.")
+	}
+}
+
+func TestBackgroundAt(t *testing.T) {
+	t.Parallel()
+
+	theme := render.Theme{Background: color.RGBA{R: 0xFF, A: 0xFF}, BackgroundBottom: color.RGBA{B: 0xFF, A: 0xFF}}
+	if !theme.HasGradient() {
+		t.Error("Expected a gradient.")
+	}
+	for _, tc := range []struct {
+		position float64
+		expect   color.RGBA
+	}{
+		{-1, color.RGBA{R: 0xFF, A: 0xFF}}, // Clamped.
+		{0, color.RGBA{R: 0xFF, A: 0xFF}},
+		{0.5, color.RGBA{R: 0x80, B: 0x80, A: 0xFF}},
+		{1, color.RGBA{B: 0xFF, A: 0xFF}},
+		{2, color.RGBA{B: 0xFF, A: 0xFF}}, // Clamped.
+	} {
+		if got := theme.BackgroundAt(tc.position); got != tc.expect {
+			t.Errorf("Unexpected background at %v.\nGot:      %v\nExpected: %v", tc.position, got, tc.expect)
+		}
+	}
+
+	if render.DefaultTheme().HasGradient() {
+		t.Error("Unexpected gradient for the default theme.")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Transform creates a new map from the given one.
+type Transform func(*Map) (*Map, error)
+
+// ResampleMethod is the interpolation used when resampling.
+type ResampleMethod byte
+
+// ResampleMethod enum values.
+const (
+	ResampleNearest ResampleMethod = iota
+	ResampleBilinear
+	ResampleBicubic
+)
+
+// ParseResampleMethod parses the method name: nearest, bilinear or bicubic.
+func ParseResampleMethod(name string) (ResampleMethod, error) {
+	switch name {
+	case "nearest":
+		return ResampleNearest, nil
+	case "bilinear":
+		return ResampleBilinear, nil
+	case "bicubic":
+		return ResampleBicubic, nil
+	default:
+		return 0, fmt.Errorf("unknown resample method %q", name)
+	}
+}
+
+// ApplyTransforms applies the given transforms in order.
+func ApplyTransforms(m *Map, transforms ...Transform) (*Map, error) {
+	for i, t := range transforms {
+		var err error
+		if m, err = t(m); err != nil {
+			return nil, fmt.Errorf("transform %d: %w", i+1, err)
+		}
+	}
+	return m, nil
+}
+
+// Transform applies the given transforms to the current map, in order.
+// The filters, if any, are re-applied on the transformed original heights.
+func (m *Fdf) Transform(transforms ...Transform) error {
+	m.restoreHeights()
+	newMap, err := ApplyTransforms(m.Map(), transforms...)
+	if err != nil {
+		m.applyFilters()
+		return err
+	}
+	m.setMap(newMap)
+	return nil
+}
+
+// Crop keeps the width x height points starting at x/y.
+func Crop(x, y, width, height int) Transform {
+	return func(m *Map) (*Map, error) {
+		w, h, err := mapSize(m)
+		if err != nil {
+			return nil, err
+		}
+		if x < 0 || y < 0 || width < 1 || height < 1 || x+width > w || y+height > h {
+			return nil, fmt.Errorf("crop %d,%d,%d,%d out of the %dx%d map", x, y, width, height, w, h)
+		}
+		return m.remap(width, height, m.CellX, m.CellY, func(nx, ny int) MapPoint { return m.Points[y+ny][x+nx] }), nil
+	}
+}
+
+// FlipX mirrors the map horizontally.
+func FlipX() Transform {
+	return func(m *Map) (*Map, error) {
+		w, h, err := mapSize(m)
+		if err != nil {
+			return nil, err
+		}
+		return m.remap(w, h, m.CellX, m.CellY, func(x, y int) MapPoint { return m.Points[y][w-1-x] }), nil
+	}
+}
+
+// FlipY mirrors the map vertically.
+func FlipY() Transform {
+	return func(m *Map) (*Map, error) {
+		w, h, err := mapSize(m)
+		if err != nil {
+			return nil, err
+		}
+		return m.remap(w, h, m.CellX, m.CellY, func(x, y int) MapPoint { return m.Points[h-1-y][x] }), nil
+	}
+}
+
+// Rot90 rotates the map by 90 degrees clockwise.
+func Rot90() Transform {
+	return func(m *Map) (*Map, error) {
+		w, h, err := mapSize(m)
+		if err != nil {
+			return nil, err
+		}
+		return m.remap(h, w, m.CellY, m.CellX, func(x, y int) MapPoint { return m.Points[h-1-x][y] }), nil
+	}
+}
+
+// Transpose swaps the rows and the columns.
+func Transpose() Transform {
+	return func(m *Map) (*Map, error) {
+		w, h, err := mapSize(m)
+		if err != nil {
+			return nil, err
+		}
+		return m.remap(h, w, m.CellY, m.CellX, func(x, y int) MapPoint { return m.Points[x][y] }), nil
+	}
+}
+
+// Resample scales the number of points by the given factor, the map keeping its extent.
+//
+// Interpolated points take the color of the nearest source point, and become holes
+// if a source point they depend on is a hole.
+func Resample(factor float64, method ResampleMethod) Transform {
+	return func(m *Map) (*Map, error) {
+		w, h, err := mapSize(m)
+		if err != nil {
+			return nil, err
+		}
+		nw, nh := int(math.Round(float64(w)*factor)), int(math.Round(float64(h)*factor))
+		if !(factor > 0) || nw < 2 || nh < 2 || nw > maxImageSide || nh > maxImageSide {
+			return nil, fmt.Errorf("invalid resample factor %v for the %dx%d map", factor, w, h)
+		}
+
+		// Align the corners so the extent is kept.
+		rx, ry := float64(w-1)/float64(nw-1), float64(h-1)/float64(nh-1)
+		return m.remap(nw, nh, m.CellX*rx, m.CellY*ry, func(x, y int) MapPoint {
+			sx, sy := float64(x)*rx, float64(y)*ry
+			p := m.Points[int(math.Round(sy))][int(math.Round(sx))]
+			if p.IsHole() {
+				return p
+			}
+			switch method {
+			case ResampleNearest:
+			case ResampleBilinear:
+				p.Z = m.bilinear(sx, sy)
+			case ResampleBicubic:
+				p.Z = m.bicubic(sx, sy)
+			}
+			return p
+		}), nil
+	}
+}
+
+// bilinear interpolates the height at the given position. NaN if touching a hole.
+func (m *Map) bilinear(x, y float64) float64 {
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, len(m.Points[0])-1), min(y0+1, len(m.Points)-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+	top := m.Points[y0][x0].Z*(1-fx) + m.Points[y0][x1].Z*fx
+	bottom := m.Points[y1][x0].Z*(1-fx) + m.Points[y1][x1].Z*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// bicubic interpolates the height at the given position with Catmull-Rom splines,
+// the points outside of the map being linearly extrapolated. Falls back to bilinear close to the holes.
+func (m *Map) bicubic(x, y float64) float64 {
+	x0, y0 := int(x), int(y)
+	fx, fy := x-float64(x0), y-float64(y0)
+	w, h := len(m.Points[0]), len(m.Points)
+
+	cubic := func(p0, p1, p2, p3, t float64) float64 {
+		return p1 + 0.5*t*(p2-p0+t*(2*p0-5*p1+4*p2-p3+t*(3*(p1-p2)+p3-p0)))
+	}
+	// Linear extrapolation keeps the slopes exact on the edges.
+	tap := func(i, n int, get func(int) float64) float64 {
+		switch {
+		case n < 2:
+			return get(0)
+		case i < 0:
+			return get(0) + float64(i)*(get(1)-get(0))
+		case i >= n:
+			return get(n-1) + float64(i-n+1)*(get(n-1)-get(n-2))
+		}
+		return get(i)
+	}
+	row := func(yy int) float64 {
+		get := func(xx int) float64 { return m.Points[yy][xx].Z }
+		return cubic(tap(x0-1, w, get), tap(x0, w, get), tap(x0+1, w, get), tap(x0+2, w, get), fx)
+	}
+	if z := cubic(tap(y0-1, h, row), tap(y0, h, row), tap(y0+1, h, row), tap(y0+2, h, row), fy); !math.IsNaN(z) {
+		return z
+	}
+	return m.bilinear(x, y)
+}
+
+// remap creates a width x height map with the given cell spacing and metadata
+// of m, each point coming from at(x, y).
+func (m *Map) remap(width, height int, cellX, cellY float64, at func(x, y int) MapPoint) *Map {
+	grid := makeGrid(width, height)
+	for y, line := range grid {
+		for x := range line {
+			p := at(x, y)
+			line[x].Z, line[x].color, line[x].explicit = p.Z, p.color, p.explicit
+		}
+	}
+	return &Map{Points: grid, CellX: cellX, CellY: cellY, Meta: m.Meta}
+}
+
+// mapSize returns the size of the map, which must be rectangular.
+func mapSize(m *Map) (width, height int, err error) {
+	if len(m.Points) == 0 || len(m.Points[0]) == 0 {
+		return 0, 0, fmt.Errorf("empty map")
+	}
+	for y, line := range m.Points {
+		if len(line) != len(m.Points[0]) {
+			return 0, 0, fmt.Errorf("transforms require a rectangular map, row %d has %d points instead of %d", y, len(line), len(m.Points[0]))
+		}
+	}
+	return len(m.Points[0]), len(m.Points), nil
+}
+
+// parseInts parses a comma separated list of n integers.
+func parseInts(str string, n int) ([]int, error) {
+	fields := strings.Split(str, ",")
+	if len(fields) != n {
+		return nil, fmt.Errorf("expected %d comma separated values, got %d", n, len(fields))
+	}
+	out := make([]int, 0, n)
+	for _, elem := range fields {
+		i, err := strconv.Atoi(strings.TrimSpace(elem))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer: %w", err)
+		}
+		out = append(out, i)
+	}
+	return out, nil
+}
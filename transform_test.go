@@ -0,0 +1,113 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	t.Parallel()
+
+	src, err := readMap(strings.NewReader("# cell: 1 2\n1 2 3,0xFF0000\n4 5 6\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("readMap: %s.", err)
+	}
+
+	for name, tc := range map[string]struct {
+		transforms []Transform
+		expect     [][]float64
+		cellX      float64
+	}{
+		"crop":      {[]Transform{Crop(1, 0, 2, 2)}, [][]float64{{2, 3}, {5, 6}}, 1},
+		"flipx":     {[]Transform{FlipX()}, [][]float64{{3, 2, 1}, {6, 5, 4}}, 1},
+		"flipy":     {[]Transform{FlipY()}, [][]float64{{4, 5, 6}, {1, 2, 3}}, 1},
+		"rot90":     {[]Transform{Rot90()}, [][]float64{{4, 1}, {5, 2}, {6, 3}}, 2},
+		"transpose": {[]Transform{Transpose()}, [][]float64{{1, 4}, {2, 5}, {3, 6}}, 2},
+		"rot360":    {[]Transform{Rot90(), Rot90(), Rot90(), Rot90()}, [][]float64{{1, 2, 3}, {4, 5, 6}}, 1},
+	} {
+		m, err := ApplyTransforms(src, tc.transforms...)
+		if err != nil {
+			t.Fatalf("%s: %s.", name, err)
+		}
+		// The heights being unique, the red point follows its height.
+		assertSameGrid(t, m.Points, makeHeightGrid(tc.expect, map[float64]color.RGBA{3: {R: 0xFF, A: 0xFF}}))
+		if m.CellX != tc.cellX {
+			t.Fatalf("Unexpected %s cell X.\nGot:      %v\nExpected: %v", name, m.CellX, tc.cellX)
+		}
+	}
+
+	// The colors are carried along.
+	m, err := ApplyTransforms(src, Rot90())
+	if err != nil {
+		t.Fatalf("rot90: %s.", err)
+	}
+	if c := m.Points[2][1].color; c != src.Points[0][2].color {
+		t.Fatalf("Unexpected rotated color %v.", c)
+	}
+
+	if _, err := ApplyTransforms(src, Crop(2, 0, 2, 2)); err == nil {
+		t.Fatal("Expected error for out of bounds crop.")
+	}
+	if _, err := ApplyTransforms(&Map{Points: [][]MapPoint{make([]MapPoint, 2), make([]MapPoint, 3)}}, FlipX()); err == nil {
+		t.Fatal("Expected error for ragged map.")
+	}
+}
+
+// makeHeightGrid returns a grid of the given heights, with the explicit colors of the given heights,
+// the default one otherwise.
+func makeHeightGrid(heights [][]float64, colors map[float64]color.RGBA) [][]MapPoint {
+	out := make([][]MapPoint, 0, len(heights))
+	for y, row := range heights {
+		line := make([]MapPoint, 0, len(row))
+		for x, h := range row {
+			p := MapPoint{color: defaultColor}
+			if c, ok := colors[h]; ok {
+				p.color, p.explicit = c, true
+			}
+			p.X, p.Y, p.Z = float64(x), float64(y), h
+			line = append(line, p)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func TestResample(t *testing.T) {
+	t.Parallel()
+
+	// Linear slope, exactly interpolated by bilinear and bicubic.
+	src, err := readMap(strings.NewReader("0 2 4 6\n0 2 4 6\n0 2 4 6\n"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("readMap: %s.", err)
+	}
+	for _, method := range []ResampleMethod{ResampleBilinear, ResampleBicubic} {
+		m, err := ApplyTransforms(src, Resample(7./4, method))
+		if err != nil {
+			t.Fatalf("Resample %d: %s.", method, err)
+		}
+		if len(m.Points) != 5 || len(m.Points[0]) != 7 {
+			t.Fatalf("Unexpected size %dx%d.", len(m.Points[0]), len(m.Points))
+		}
+		for x, elem := range m.Points[2] {
+			if expect := float64(x); math.Abs(elem.Z-expect) > 1e-9 {
+				t.Fatalf("Unexpected height for method %d at %d.\nGot:      %v\nExpected: %v", method, x, elem.Z, expect)
+			}
+		}
+		// Extent kept: 3 cells of 1 become 6 cells of 0.5.
+		if m.CellX != 0.5 {
+			t.Fatalf("Unexpected cell X %v.", m.CellX)
+		}
+	}
+
+	// Holes spread to the interpolated points depending on them.
+	src.Points[1][1].Z = math.NaN()
+	m, err := ApplyTransforms(src, Resample(2, ResampleBilinear))
+	if err != nil {
+		t.Fatalf("Resample: %s.", err)
+	}
+	if !m.Points[2][2].IsHole() || m.Points[5][7].IsHole() {
+		t.Fatal("Unexpected holes.")
+	}
+}